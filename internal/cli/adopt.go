@@ -0,0 +1,223 @@
+package cli
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/joaosaffran/mob/internal/github"
+	"github.com/joaosaffran/mob/internal/tracking"
+	"github.com/spf13/cobra"
+)
+
+// issueNumberPattern finds the issue number in branch names like feature/123-login
+var issueNumberPattern = regexp.MustCompile(`\d+`)
+
+// defaultBaseBranch returns the branch origin's HEAD points at, or GitHub's default branch
+func defaultBaseBranch() (string, error) {
+	if branch, err := repo.RemoteDefaultBranch("origin"); err == nil {
+		return branch, nil
+	}
+	branch, err := github.GetDefaultBranch()
+	if err != nil {
+		return "", fmt.Errorf("can't tell the base branch, pass --base: %w", err)
+	}
+	return branch, nil
+}
+
+// findMergedCommits returns the wip commits whose changes the pr branch already has, newest
+// first. Squashed pr branches match the wip commit with the same tree or the same overall
+// patch; otherwise commits are matched one by one by patch ID.
+func findMergedCommits(forkPoint, wipBranch, prRef string) ([]string, error) {
+	commits, err := repo.GetCommitsBetween(forkPoint, wipBranch)
+	if err != nil {
+		return nil, err
+	}
+	prTree, err := repo.TreeOf(prRef)
+	if err != nil {
+		return nil, err
+	}
+	prBase, err := repo.MergeBase(prRef, wipBranch)
+	if err != nil {
+		return nil, err
+	}
+	prPatch, err := repo.PatchID(prBase, prRef)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, c := range commits {
+		tree, err := repo.TreeOf(c)
+		if err != nil {
+			return nil, err
+		}
+		if tree == prTree {
+			return commits[i:], nil
+		}
+		if prPatch == "" {
+			continue
+		}
+		patch, err := repo.PatchID(forkPoint, c)
+		if err != nil {
+			return nil, err
+		}
+		if patch == prPatch {
+			return commits[i:], nil
+		}
+	}
+
+	prCommits, err := repo.CommitPatchIDs(prBase, prRef)
+	if err != nil {
+		return nil, err
+	}
+	onPR := make(map[string]bool)
+	for _, c := range prCommits {
+		onPR[c.PatchID] = true
+	}
+	ids, err := repo.PatchIDs(commits)
+	if err != nil {
+		return nil, err
+	}
+	var merged []string
+	for _, c := range commits {
+		if onPR[ids[c]] {
+			merged = append(merged, c)
+		}
+	}
+	return merged, nil
+}
+
+var adoptCmd = &cobra.Command{
+	Use:   "adopt <branch>",
+	Short: "Start tracking an existing branch",
+	Long: `Brings a branch created without mob under mob tracking. The fork point is the merge-base
+with the base branch. With --rename the branch is renamed to wip/<issue>. If pr/<issue> already
+exists, the wip commits it already contains are recorded as merged, so 'mob update' only carries
+new ones.
+
+The issue is taken from the branch name unless --issue is given: the rest of a wip/<issue>
+name, or the number in names like feature/42-login.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		branch := args[0]
+		issue, _ := cmd.Flags().GetString("issue")
+		baseBranch, _ := cmd.Flags().GetString("base")
+		rename, _ := cmd.Flags().GetBool("rename")
+
+		if !repo.BranchExists("refs/heads/" + branch) {
+			return fmt.Errorf("branch '%s' doesn't exist", branch)
+		}
+
+		// A wip branch already names its issue; other names need a number in them
+		if issue == "" {
+			if work, ok := strings.CutPrefix(branch, "wip/"); ok {
+				issue = work
+			} else if issue = issueNumberPattern.FindString(branch); issue == "" {
+				return fmt.Errorf("can't find an issue number in '%s', pass --issue", branch)
+			}
+		}
+		wipBranch := fmt.Sprintf("wip/%s", issue)
+		prBranch := fmt.Sprintf("pr/%s", issue)
+
+		trackingData, err := tracking.Load()
+		if err != nil {
+			return fmt.Errorf("error loading tracking data: %w", err)
+		}
+		if _, ok := trackingData.Issues[issue]; ok {
+			return fmt.Errorf("issue #%s is already tracked", issue)
+		}
+		if rename && branch != wipBranch && repo.BranchExists("refs/heads/"+wipBranch) {
+			return fmt.Errorf("'%s' already exists", wipBranch)
+		}
+
+		if baseBranch == "" {
+			if baseBranch, err = defaultBaseBranch(); err != nil {
+				return err
+			}
+		}
+		baseBranch = strings.TrimPrefix(baseBranch, "origin/")
+		baseRef := baseBranch
+		if repo.BranchExists("refs/remotes/origin/" + baseBranch) {
+			baseRef = "origin/" + baseBranch
+		}
+
+		forkPoint, err := repo.MergeBase(baseRef, branch)
+		if err != nil {
+			return fmt.Errorf("error finding the fork point from '%s': %w", baseRef, err)
+		}
+
+		// An existing pr branch tells which commits were already carried over
+		prRef := ""
+		switch {
+		case repo.BranchExists("refs/heads/" + prBranch):
+			prRef = "refs/heads/" + prBranch
+		case repo.BranchExists("refs/remotes/origin/" + prBranch):
+			prRef = "refs/remotes/origin/" + prBranch
+		}
+		var merged []string
+		if prRef != "" {
+			if merged, err = findMergedCommits(forkPoint, branch, prRef); err != nil {
+				return fmt.Errorf("error comparing with '%s': %w", prBranch, err)
+			}
+		}
+
+		// Rollback function to restore the branches on failure
+		originalBranch := branch
+		createdPR := false
+		rollback := func(errMsg string) error {
+			keepRunning()
+			if createdPR {
+				repo.DeleteBranch(prBranch)
+			}
+			if branch != originalBranch {
+				repo.RenameBranch(branch, originalBranch)
+			}
+			return fmt.Errorf("%s (changes rolled back)", errMsg)
+		}
+
+		if rename && branch != wipBranch {
+			if err := repo.RenameBranch(branch, wipBranch); err != nil {
+				return fmt.Errorf("error renaming '%s' to '%s': %w", branch, wipBranch, err)
+			}
+			branch = wipBranch
+		}
+
+		// update builds on the local pr branch, so create it from the remote one
+		if strings.HasPrefix(prRef, "refs/remotes/") {
+			if err := repo.SetBranch(prBranch, prRef); err != nil {
+				return rollback(fmt.Sprintf("error creating '%s': %v", prBranch, err))
+			}
+			createdPR = true
+		}
+
+		err = tracking.Update(func(trackingData *tracking.TrackingData) error {
+			trackingData.SetForkPoint(issue, forkPoint)
+			trackingData.SetBaseBranch(issue, baseBranch)
+			trackingData.AddEvent(issue, tracking.Event{Type: tracking.EventCreated, At: time.Now()})
+			if len(merged) > 0 {
+				return trackingData.SetMergedCommits(issue, merged[0], merged, repo.PatchIDs)
+			}
+			return nil
+		})
+		if err != nil {
+			return rollback(fmt.Sprintf("error saving tracking data: %v", err))
+		}
+
+		fmt.Printf("Adopted '%s' as issue #%s (fork point %s on '%s')\n", branch, issue, shortHash(forkPoint), baseBranch)
+		if prRef != "" {
+			fmt.Printf("'%s' already contains %d commit(s)\n", prBranch, len(merged))
+		}
+		if branch != wipBranch {
+			fmt.Printf("Note: mob commands run on wip branches; rename it with 'git branch -m %s %s'\n", branch, wipBranch)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(adoptCmd)
+	adoptCmd.Flags().StringP("issue", "i", "", "Issue number (defaults to the number in the branch name)")
+	adoptCmd.Flags().StringP("base", "b", "", "Base branch (defaults to the remote's default branch)")
+	adoptCmd.Flags().Bool("rename", false, "Rename the branch to wip/<issue>")
+}
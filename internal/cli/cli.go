@@ -1,14 +1,69 @@
 package cli
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/joaosaffran/mob/internal/git"
+	"github.com/joaosaffran/mob/internal/github"
 	"github.com/spf13/cobra"
 )
 
+// repo is the repository commands work on, opened before any command runs
+var repo = git.Default()
+
 var rootCmd = &cobra.Command{
 	Use:   "mob",
 	Short: "A CLI tool to manage git workflow",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// Shell completion scripts don't need a repository
+		for c := cmd; c != nil; c = c.Parent() {
+			if c.Name() == "completion" {
+				return nil
+			}
+		}
+
+		dir, _ := cmd.Flags().GetString("repo")
+		r, err := git.Open(cmd.Context(), dir)
+		if errors.Is(err, git.ErrNotARepository) {
+			if dir == "" {
+				dir = "the current directory"
+			}
+			return fmt.Errorf("%s is not in a git repository", dir)
+		}
+		if err != nil {
+			return fmt.Errorf("error opening repository: %w", err)
+		}
+
+		repo = r
+		git.SetDefault(r)
+		github.SetRepoDir(r.Dir)
+		return nil
+	},
+}
+
+// keepRunning stops an interrupt from cancelling the git commands that follow, so a
+// rollback isn't cut short by the interrupt that caused it
+func keepRunning() {
+	repo = repo.WithoutCancel()
+	git.SetDefault(repo)
 }
 
 func Execute() error {
-	return rootCmd.Execute()
+	// The first interrupt cancels running git commands so operations can roll back;
+	// a second one kills mob as usual
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return rootCmd.ExecuteContext(ctx)
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringP("repo", "C", "", "Run as if mob was started in this directory")
 }
@@ -0,0 +1,156 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/charmbracelet/huh"
+	"github.com/joaosaffran/mob/internal/git"
+	"github.com/joaosaffran/mob/internal/ui"
+)
+
+// Ways forward offered when applying commits stops on conflicts
+const (
+	conflictResolveInteractively = iota
+	conflictTakeWip
+	conflictAbort
+)
+
+// mergeStrategies are the values accepted by --strategy
+var mergeStrategies = []string{"theirs", "ours"}
+
+// conflictMarker starts the first line of a conflict block
+var conflictMarker = []byte("<<<<<<< ")
+
+// validateStrategy checks that a --strategy value is supported
+func validateStrategy(strategy string) error {
+	if strategy == "" {
+		return nil
+	}
+	for _, s := range mergeStrategies {
+		if s == strategy {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid strategy '%s' (expected one of %v)", strategy, mergeStrategies)
+}
+
+// resolveWithStrategy resolves conflicting hunks in favor of one side ("ours" is the pr
+// branch, "theirs" the wip branch). Files deleted on the favored side are removed.
+func resolveWithStrategy(idx *git.Index, conflicts []git.Conflict, strategy string) error {
+	for _, c := range conflicts {
+		blob, mode := c.Theirs, c.TheirsMode
+		if strategy == "ours" {
+			blob, mode = c.Ours, c.OursMode
+		}
+
+		// Only merge contents when both sides still have the file
+		if c.Ours != "" && c.Theirs != "" {
+			content, _, err := repo.MergeFile(c, strategy)
+			if err != nil {
+				return fmt.Errorf("error merging '%s': %w", c.Path, err)
+			}
+			if blob, err = repo.HashObject(content); err != nil {
+				return fmt.Errorf("error storing '%s': %w", c.Path, err)
+			}
+		}
+
+		if err := idx.Resolve(c.Path, mode, blob); err != nil {
+			return fmt.Errorf("error resolving '%s': %w", c.Path, err)
+		}
+	}
+	return nil
+}
+
+// takeWipSide resolves conflicted files with their whole wip version
+func takeWipSide(idx *git.Index, conflicts []git.Conflict) error {
+	for _, c := range conflicts {
+		if err := idx.Resolve(c.Path, c.TheirsMode, c.Theirs); err != nil {
+			return fmt.Errorf("error resolving '%s': %w", c.Path, err)
+		}
+	}
+	return nil
+}
+
+// editConflicts opens each conflicted file, with conflict markers, in the git editor
+// and stores the edited result. The files live in a temporary directory, so the
+// working tree is never touched.
+func editConflicts(idx *git.Index, conflicts []git.Conflict) error {
+	dir, err := os.MkdirTemp("", "mob-conflicts-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	for _, c := range conflicts {
+		if c.Ours == "" || c.Theirs == "" {
+			return fmt.Errorf("'%s' was deleted on one side and can't be edited; take the wip side instead", c.Path)
+		}
+
+		content, _, err := repo.MergeFile(c, "")
+		if err != nil {
+			return fmt.Errorf("error merging '%s': %w", c.Path, err)
+		}
+
+		// Keep the file name so the editor picks the right syntax
+		path := filepath.Join(dir, filepath.Base(c.Path))
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			return err
+		}
+		if err := repo.EditFile(path); err != nil {
+			return fmt.Errorf("error editing '%s': %w", c.Path, err)
+		}
+
+		edited, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if bytes.Contains(edited, conflictMarker) {
+			return fmt.Errorf("'%s' still has conflict markers", c.Path)
+		}
+
+		blob, err := repo.HashObject(edited)
+		if err != nil {
+			return fmt.Errorf("error storing '%s': %w", c.Path, err)
+		}
+		if err := idx.Resolve(c.Path, c.TheirsMode, blob); err != nil {
+			return fmt.Errorf("error resolving '%s': %w", c.Path, err)
+		}
+	}
+	return nil
+}
+
+// resolveConflicts resolves the conflicts left in idx. With a strategy they are resolved
+// automatically; otherwise the conflicting files are listed and the user picks a way forward.
+// It returns an error if the update should be rolled back.
+func resolveConflicts(idx *git.Index, conflicts []git.Conflict, strategy string) error {
+	if strategy != "" {
+		return resolveWithStrategy(idx, conflicts, strategy)
+	}
+
+	fmt.Printf("Stopped with %d conflicting file(s):\n", len(conflicts))
+	for _, c := range conflicts {
+		fmt.Printf("  %s\n", c.Path)
+	}
+
+	options := []huh.Option[int]{
+		huh.NewOption("Resolve interactively (edit conflict markers)", conflictResolveInteractively),
+		huh.NewOption("Take the wip side for all conflicts", conflictTakeWip),
+		huh.NewOption("Abort and roll back", conflictAbort),
+	}
+	choice, err := ui.ShowForm(options, "How do you want to continue?")
+	if err != nil {
+		return fmt.Errorf("error selecting conflict resolution: %w", err)
+	}
+
+	switch choice {
+	case conflictResolveInteractively:
+		return editConflicts(idx, conflicts)
+	case conflictTakeWip:
+		return takeWipSide(idx, conflicts)
+	default:
+		return fmt.Errorf("merge aborted")
+	}
+}
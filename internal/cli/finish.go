@@ -0,0 +1,378 @@
+package cli
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/joaosaffran/mob/internal/github"
+	"github.com/joaosaffran/mob/internal/journal"
+	"github.com/joaosaffran/mob/internal/tracking"
+	"github.com/spf13/cobra"
+)
+
+// mergeInfo tells where an issue's changes were merged
+type mergeInfo struct {
+	Commit   string
+	MergedAt time.Time
+	Source   string
+}
+
+// findMergeOnGitHub asks GitHub whether the issue's pull request was merged. open is set
+// when it is still open; with neither set GitHub couldn't tell, and the base branch is
+// checked instead.
+func findMergeOnGitHub(issueTracking tracking.IssueTracking, prBranch string) (info *mergeInfo, open bool) {
+	var pr *github.PullRequest
+	var err error
+	if issueTracking.PRNumber > 0 {
+		pr, err = github.GetPullRequest(issueTracking.PRNumber)
+	} else {
+		pr, err = github.GetPullRequestForBranch(prBranch)
+	}
+	if err != nil {
+		return nil, false
+	}
+
+	switch pr.State {
+	case "MERGED":
+		info := &mergeInfo{MergedAt: pr.MergedAt, Source: fmt.Sprintf("pull request #%d", pr.Number)}
+		if pr.MergeCommit != nil {
+			info.Commit = pr.MergeCommit.OID
+		}
+		return info, false
+	case "OPEN":
+		return nil, true
+	}
+	// A closed pull request may still have been applied by hand
+	return nil, false
+}
+
+// findMergeInBase looks for the pr branch's changes in the base branch: merged as is,
+// squashed into one commit, or rebased commit by commit. It returns nil if they aren't there.
+func findMergeInBase(issueTracking tracking.IssueTracking, prBranch string) (*mergeInfo, error) {
+	base := issueTracking.BaseBranch
+	if base == "" {
+		return nil, fmt.Errorf("no base branch recorded")
+	}
+	baseRef := base
+	if repo.BranchExists("refs/remotes/origin/" + base) {
+		baseRef = "origin/" + base
+	}
+
+	prRef := "refs/heads/" + prBranch
+	if !repo.BranchExists(prRef) {
+		prRef = "refs/remotes/origin/" + prBranch
+		if !repo.BranchExists(prRef) {
+			return nil, fmt.Errorf("'%s' doesn't exist locally or on origin", prBranch)
+		}
+	}
+
+	// A pr branch still at the fork point carries nothing, and would look merged below
+	forkPoint := issueTracking.ForkPoint
+	if forkPoint == "" {
+		return nil, fmt.Errorf("no fork point recorded")
+	}
+	carried, err := repo.GetCommitsBetween(forkPoint, prRef)
+	if err != nil {
+		return nil, err
+	}
+	if len(carried) == 0 {
+		return nil, nil
+	}
+
+	found := func(commit string) (*mergeInfo, error) {
+		date, err := repo.CommitDate(commit)
+		if err != nil {
+			return nil, err
+		}
+		return &mergeInfo{Commit: commit, MergedAt: date, Source: baseRef}, nil
+	}
+
+	// Merged with a merge commit or fast-forwarded
+	if repo.IsAncestor(prRef, baseRef) {
+		commit, err := repo.FirstCommitOnPath(prRef, baseRef)
+		if err != nil {
+			return nil, err
+		}
+		if commit == "" {
+			commit = prRef
+		}
+		return found(commit)
+	}
+
+	baseCommits, err := repo.CommitPatchIDs(forkPoint, baseRef)
+	if err != nil {
+		return nil, err
+	}
+
+	// Squash merged: one base commit carries all the pr changes
+	patchID, err := repo.PatchID(forkPoint, prRef)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range baseCommits {
+		if c.PatchID == patchID {
+			return found(c.Commit)
+		}
+	}
+
+	// Rebase merged: every pr commit has an equivalent in the base branch
+	prCommits, err := repo.CommitPatchIDs(forkPoint, prRef)
+	if err != nil || len(prCommits) == 0 {
+		return nil, err
+	}
+	wanted := make(map[string]bool)
+	for _, c := range prCommits {
+		wanted[c.PatchID] = true
+	}
+	latest := ""
+	for _, c := range baseCommits {
+		if wanted[c.PatchID] {
+			if latest == "" {
+				latest = c.Commit
+			}
+			delete(wanted, c.PatchID)
+		}
+	}
+	if len(wanted) > 0 {
+		return nil, nil
+	}
+	return found(latest)
+}
+
+// finishIssue deletes the branches of a merged issue and archives its tracking entry
+func finishIssue(issue string, info *mergeInfo, force bool) error {
+	trackingData, err := tracking.Load()
+	if err != nil {
+		return fmt.Errorf("error loading tracking data: %w", err)
+	}
+	issueTracking := trackingData.GetIssueTracking(issue)
+	wipBranch := fmt.Sprintf("wip/%s", issue)
+	prBranch := fmt.Sprintf("pr/%s", issue)
+
+	// Don't throw away work that never reached the pr branch
+	if repo.BranchExists(wipBranch) && issueTracking.ForkPoint != "" && !force {
+		commits, err := repo.GetCommitsBetween(issueTracking.ForkPoint, wipBranch)
+		if err != nil {
+			return fmt.Errorf("error getting commits: %w", err)
+		}
+		if unmerged := trackingData.GetUnmergedCommits(issue, commits, repo.PatchIDs); len(unmerged) > 0 {
+			return fmt.Errorf("'%s' has %d commit(s) that were never merged into '%s'; use --force to delete it anyway", wipBranch, len(unmerged), prBranch)
+		}
+	}
+
+	args := []string{"finish", issue}
+	if force {
+		args = append(args, "--force")
+	}
+	j, err := journal.Begin("finish", issue, args)
+	if err != nil {
+		return err
+	}
+	for _, branch := range []string{wipBranch, prBranch} {
+		if err := j.Record(branch); err != nil {
+			j.Finish()
+			return fmt.Errorf("error writing journal: %w", err)
+		}
+	}
+
+	rollback := func(errMsg string) error {
+		keepRunning()
+		if err := j.Undo(); err != nil {
+			return fmt.Errorf("%s (rollback failed: %v)", errMsg, err)
+		}
+		return fmt.Errorf("%s (changes rolled back)", errMsg)
+	}
+
+	// Remote branches are deleted last since that can't be undone, but find them now
+	// so an unreachable remote stops finish before anything changed
+	var remoteBranches []string
+	for _, branch := range []string{prBranch, wipBranch} {
+		exists, err := repo.RemoteRefExists("origin", "refs/heads/"+branch)
+		if err != nil {
+			return rollback(fmt.Sprintf("error checking 'origin/%s': %v", branch, err))
+		}
+		if exists {
+			remoteBranches = append(remoteBranches, branch)
+		}
+	}
+
+	// A branch can't be deleted while it is checked out, so move to the base branch.
+	// Undo then stays there too, since it checks out the original branch before restoring others.
+	onIssueBranch := j.OriginalBranch == wipBranch || j.OriginalBranch == prBranch
+	if onIssueBranch {
+		if issueTracking.BaseBranch == "" {
+			return rollback(fmt.Sprintf("no base branch recorded for #%s; check out another branch first", issue))
+		}
+		j.OriginalBranch = issueTracking.BaseBranch
+	}
+	if err := j.SetStep("delete-local"); err != nil {
+		return rollback(fmt.Sprintf("error writing journal: %v", err))
+	}
+	if onIssueBranch {
+		if err := repo.Checkout(issueTracking.BaseBranch); err != nil {
+			return rollback(fmt.Sprintf("error checking out '%s': %v", issueTracking.BaseBranch, err))
+		}
+	}
+	var deleted []string
+	for _, branch := range []string{wipBranch, prBranch} {
+		if !repo.BranchExists("refs/heads/" + branch) {
+			continue
+		}
+		if err := repo.DeleteBranch(branch); err != nil {
+			return rollback(fmt.Sprintf("error deleting '%s': %v", branch, err))
+		}
+		deleted = append(deleted, branch)
+	}
+
+	if err := j.SetStep("save-tracking"); err != nil {
+		return rollback(fmt.Sprintf("error writing journal: %v", err))
+	}
+	err = tracking.Update(func(trackingData *tracking.TrackingData) error {
+		trackingData.ArchiveIssue(issue, info.Commit, info.MergedAt)
+		return nil
+	})
+	if err != nil {
+		return rollback(fmt.Sprintf("error saving tracking data: %v", err))
+	}
+
+	// The issue is finished locally, so a remote branch that can't be deleted is only reported
+	if err := j.Finish(); err != nil {
+		return fmt.Errorf("error removing journal: %w", err)
+	}
+	var failed []string
+	for _, branch := range remoteBranches {
+		if err := repo.DeleteRemoteBranch("origin", branch); err != nil {
+			failed = append(failed, fmt.Sprintf("Warning: couldn't delete 'origin/%s', delete it with 'git push origin --delete %s': %v", branch, branch, err))
+			continue
+		}
+		deleted = append(deleted, "origin/"+branch)
+	}
+	for _, branch := range []string{prBranch, wipBranch} {
+		// The remote may have deleted it already, leaving a stale remote-tracking ref
+		if repo.BranchExists("refs/remotes/origin/"+branch) && !slices.Contains(remoteBranches, branch) {
+			repo.DeleteRef("refs/remotes/origin/" + branch)
+		}
+	}
+
+	merged := "merged"
+	if info.Commit != "" {
+		merged += " in " + shortHash(info.Commit)
+	}
+	if !info.MergedAt.IsZero() {
+		merged += " on " + info.MergedAt.Local().Format("2006-01-02")
+	}
+	fmt.Printf("Finished #%s (%s, per %s)\n", issue, merged, info.Source)
+	if len(deleted) > 0 {
+		fmt.Printf("  deleted %s\n", strings.Join(deleted, ", "))
+	}
+	for _, warning := range failed {
+		fmt.Println(warning)
+	}
+	return nil
+}
+
+// findMerge checks whether an issue was merged, asking GitHub first unless noPR is set
+func findMerge(issue string, issueTracking tracking.IssueTracking, noPR bool) (*mergeInfo, error) {
+	prBranch := fmt.Sprintf("pr/%s", issue)
+	if !noPR {
+		info, open := findMergeOnGitHub(issueTracking, prBranch)
+		if info != nil || open {
+			return info, nil
+		}
+	}
+	return findMergeInBase(issueTracking, prBranch)
+}
+
+var finishCmd = &cobra.Command{
+	Use:   "finish [issue]",
+	Short: "Clean up an issue whose pull request was merged",
+	Long: `Checks that the pull request of an issue was merged, deletes its wip and pr branches
+locally and on origin, and moves its tracking entry to the archive with the merge commit and date.
+
+The merge is confirmed through GitHub when possible. Otherwise the base branch is searched
+for the pr branch's changes, whether they were merged, squashed or rebased.
+Without an issue, the issue of the current wip or pr branch is used.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runFinish,
+}
+
+// runFinish finishes the given issue, or every merged one with --all-merged
+func runFinish(cmd *cobra.Command, args []string) error {
+	allMerged, _ := cmd.Flags().GetBool("all-merged")
+	force, _ := cmd.Flags().GetBool("force")
+	noPR, _ := cmd.Flags().GetBool("no-pr")
+	if allMerged && len(args) > 0 {
+		return fmt.Errorf("--all-merged cannot be used with an issue")
+	}
+
+	var issues []string
+	if !allMerged {
+		issue, err := issueArg(args)
+		if err != nil {
+			return err
+		}
+		issues = []string{issue}
+	}
+
+	if err := repo.Fetch("origin"); err != nil {
+		fmt.Printf("Warning: could not fetch origin, checking local refs only: %v\n", err)
+	}
+
+	trackingData, err := tracking.Load()
+	if err != nil {
+		return fmt.Errorf("error loading tracking data: %w", err)
+	}
+	if allMerged {
+		for issue := range trackingData.Issues {
+			issues = append(issues, issue)
+		}
+		sort.Strings(issues)
+	}
+
+	finished := 0
+	for _, issue := range issues {
+		issueTracking, ok := trackingData.Issues[issue]
+		if !ok {
+			return fmt.Errorf("issue #%s is not tracked", issue)
+		}
+
+		info, err := findMerge(issue, issueTracking, noPR)
+		if err != nil {
+			if !allMerged {
+				return fmt.Errorf("error checking whether #%s was merged: %w", issue, err)
+			}
+			fmt.Printf("Skipping #%s: %v\n", issue, err)
+			continue
+		}
+		if info == nil {
+			if !allMerged {
+				return fmt.Errorf("the pull request for #%s hasn't been merged", issue)
+			}
+			continue
+		}
+
+		if err := finishIssue(issue, info, force); err != nil {
+			if !allMerged {
+				return err
+			}
+			fmt.Printf("Skipping #%s: %v\n", issue, err)
+			continue
+		}
+		finished++
+	}
+
+	if allMerged {
+		fmt.Printf("Finished %d of %d tracked issue(s)\n", finished, len(issues))
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(finishCmd)
+	finishCmd.Flags().Bool("all-merged", false, "Finish every tracked issue that was merged")
+	finishCmd.Flags().Bool("force", false, "Delete the wip branch even if it has commits that never reached the pr branch")
+	finishCmd.Flags().Bool("no-pr", false, "Don't query GitHub; look for the changes in the base branch instead")
+}
@@ -0,0 +1,199 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/joaosaffran/mob/internal/config"
+	"github.com/joaosaffran/mob/internal/git"
+)
+
+// commitSettings are the identity and signing options of the commits mob writes to pr branches
+type commitSettings struct {
+	author     *git.Identity
+	committer  *git.Identity
+	sign       bool
+	signingKey string
+	signFormat string
+}
+
+// loadCommitSettings reads the commit section of .mob/config.yaml, falling back to
+// commit.gpgsign to decide whether to sign
+func loadCommitSettings() (commitSettings, error) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return commitSettings{}, fmt.Errorf("error loading config: %w", err)
+	}
+
+	settings := commitSettings{
+		author:     configIdentity(cfg.Commit.Author),
+		committer:  configIdentity(cfg.Commit.Committer),
+		signingKey: cfg.Commit.SigningKey,
+		signFormat: cfg.Commit.Format,
+	}
+	if cfg.Commit.Sign != nil {
+		settings.sign = *cfg.Commit.Sign
+	} else if settings.sign, err = repo.ConfigBool("commit.gpgsign", false); err != nil {
+		return commitSettings{}, fmt.Errorf("error reading commit.gpgsign: %w", err)
+	}
+	return settings, nil
+}
+
+// configIdentity returns the identity to override git's with, or nil to keep git's
+func configIdentity(id config.IdentityConfig) *git.Identity {
+	if id.Name == "" && id.Email == "" {
+		return nil
+	}
+	return &git.Identity{Name: id.Name, Email: id.Email}
+}
+
+// commitTree creates a commit with the configured committer and signature. The configured
+// author is used unless opts already names one. When signing is on, the commit's signature
+// is checked, so a commit branch protection would reject never reaches the pr branch.
+func (s commitSettings) commitTree(tree string, opts git.CommitOptions) (string, error) {
+	if opts.Author == nil {
+		opts.Author = s.author
+	}
+	opts.Committer = s.committer
+	opts.Sign = s.sign
+	opts.SigningKey = s.signingKey
+	opts.SignFormat = s.signFormat
+
+	commit, err := repo.CommitTree(tree, opts)
+	if err != nil {
+		if s.sign {
+			return "", fmt.Errorf("%w (signing is on; check user.signingkey and gpg.format, or set commit.sign in .mob/config.yaml)", err)
+		}
+		return "", err
+	}
+	if s.sign {
+		if err := checkSignature(commit); err != nil {
+			return "", err
+		}
+	}
+	return commit, nil
+}
+
+// checkSignature returns an error unless commit carries a signature git accepts. A
+// signature git can't check locally (for example an SSH signature without
+// gpg.ssh.allowedSignersFile) is accepted, since the forge verifies it against its own keys.
+func checkSignature(commit string) error {
+	status, err := repo.SignatureStatus(commit)
+	if err != nil {
+		return fmt.Errorf("error verifying the signature of %s: %w", shortHash(commit), err)
+	}
+	switch status {
+	case "G", "U", "E":
+		return nil
+	case "N":
+		return fmt.Errorf("commit %s wasn't signed", shortHash(commit))
+	case "B":
+		return fmt.Errorf("commit %s has a bad signature", shortHash(commit))
+	case "X", "Y":
+		return fmt.Errorf("commit %s was signed with an expired signature or key", shortHash(commit))
+	case "R":
+		return fmt.Errorf("commit %s was signed with a revoked key", shortHash(commit))
+	default:
+		return fmt.Errorf("commit %s has an unrecognized signature status '%s'", shortHash(commit), status)
+	}
+}
+
+// reversed returns commits in the opposite order (git log lists newest first)
+func reversed(commits []string) []string {
+	result := make([]string, len(commits))
+	for i, c := range commits {
+		result[len(commits)-1-i] = c
+	}
+	return result
+}
+
+// applyToIndex applies a commit's changes against its first parent, resolving any conflicts
+func applyToIndex(idx *git.Index, commit, strategy string) error {
+	if err := idx.ApplyCommit(commit+"^", commit); err != nil {
+		conflicts, conflictErr := idx.Conflicts()
+		if conflictErr != nil || len(conflicts) == 0 {
+			return fmt.Errorf("error applying %s: %w", shortHash(commit), err)
+		}
+		if err := resolveConflicts(idx, conflicts, strategy); err != nil {
+			return fmt.Errorf("error applying %s: %w", shortHash(commit), err)
+		}
+	}
+	return nil
+}
+
+// buildSquashCommit applies commits (oldest first) on top of parent and records them as a
+// single commit. Each commit is applied against its own parent, so changes that already
+// reached the pr branch in an earlier update don't conflict again.
+func buildSquashCommit(settings commitSettings, parent string, commits []string, strategy, message string) (string, error) {
+	idx, err := repo.NewIndex(parent)
+	if err != nil {
+		return "", fmt.Errorf("error creating index: %w", err)
+	}
+	defer idx.Remove()
+
+	for _, commit := range commits {
+		if err := applyToIndex(idx, commit, strategy); err != nil {
+			return "", err
+		}
+	}
+
+	tree, err := idx.WriteTree()
+	if err != nil {
+		return "", fmt.Errorf("error writing tree: %w", err)
+	}
+
+	commit, err := settings.commitTree(tree, git.CommitOptions{Parents: []string{parent}, Message: message})
+	if err != nil {
+		return "", fmt.Errorf("error creating squash commit: %w", err)
+	}
+	return commit, nil
+}
+
+// buildTreeCommit records the tree of ref as a single commit on top of parent
+func buildTreeCommit(settings commitSettings, parent, ref, message string) (string, error) {
+	tree, err := repo.TreeOf(ref)
+	if err != nil {
+		return "", fmt.Errorf("error reading tree of '%s': %w", ref, err)
+	}
+
+	commit, err := settings.commitTree(tree, git.CommitOptions{Parents: []string{parent}, Message: message})
+	if err != nil {
+		return "", fmt.Errorf("error creating squash commit: %w", err)
+	}
+	return commit, nil
+}
+
+// buildPreservedCommits replays commits (oldest first) on top of parent, keeping their
+// messages and authors, and returns the new tip
+func buildPreservedCommits(settings commitSettings, parent string, commits []string, strategy string) (string, error) {
+	idx, err := repo.NewIndex(parent)
+	if err != nil {
+		return "", fmt.Errorf("error creating index: %w", err)
+	}
+	defer idx.Remove()
+
+	head := parent
+	for _, commit := range commits {
+		if err := applyToIndex(idx, commit, strategy); err != nil {
+			return "", err
+		}
+
+		tree, err := idx.WriteTree()
+		if err != nil {
+			return "", fmt.Errorf("error writing tree: %w", err)
+		}
+		message, err := repo.GetCommitMessage(commit)
+		if err != nil {
+			return "", fmt.Errorf("error getting commit message: %w", err)
+		}
+		author, err := repo.GetCommitAuthor(commit)
+		if err != nil {
+			return "", fmt.Errorf("error getting commit author: %w", err)
+		}
+
+		head, err = settings.commitTree(tree, git.CommitOptions{Parents: []string{head}, Message: message, Author: &author})
+		if err != nil {
+			return "", fmt.Errorf("error copying %s: %w", shortHash(commit), err)
+		}
+	}
+	return head, nil
+}
@@ -0,0 +1,288 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/joaosaffran/mob/internal/git"
+	"github.com/joaosaffran/mob/internal/tracking"
+	"github.com/spf13/cobra"
+)
+
+// hookNames are the git hooks mob installs
+var hookNames = []string{"pre-commit", "pre-push"}
+
+// hookMarker identifies hooks written by mob
+const hookMarker = "# mob hook"
+
+// chainedSuffix is appended to a hook that was in place before mob's, which still runs first
+const chainedSuffix = ".pre-mob"
+
+// hookScript runs the hook mob replaced, then mob's own check. Both get the hook's
+// arguments and stdin.
+const hookScript = `#!/bin/sh
+%[1]s: installed by 'mob hooks install', removed by 'mob hooks uninstall'
+chained="$(dirname "$0")/%[2]s%[3]s"
+input="$(mktemp)"
+trap 'rm -f "$input"' EXIT
+cat > "$input"
+if [ -x "$chained" ]; then
+	"$chained" "$@" < "$input" || exit $?
+fi
+%[4]s hooks run %[2]s "$@" < "$input"
+`
+
+// shellQuote quotes a string for sh
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// isMobHook reports whether the hook at path was written by mob
+func isMobHook(path string) (bool, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Contains(content, []byte(hookMarker)), nil
+}
+
+// installHook writes mob's hook, moving a hook that is already there aside so it keeps running
+func installHook(dir, name, mobPath string) (chained bool, err error) {
+	path := filepath.Join(dir, name)
+	ours, err := isMobHook(path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	if err == nil && !ours {
+		if _, err := os.Stat(path + chainedSuffix); err == nil {
+			return false, fmt.Errorf("both '%s' and '%s' exist; remove one first", path, path+chainedSuffix)
+		}
+		if err := os.Rename(path, path+chainedSuffix); err != nil {
+			return false, err
+		}
+	}
+
+	script := fmt.Sprintf(hookScript, hookMarker, name, chainedSuffix, shellQuote(filepath.ToSlash(mobPath)))
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		return false, err
+	}
+	_, err = os.Stat(path + chainedSuffix)
+	return err == nil, nil
+}
+
+// uninstallHook removes mob's hook and puts back the one it replaced
+func uninstallHook(dir, name string) (removed bool, err error) {
+	path := filepath.Join(dir, name)
+	ours, err := isMobHook(path)
+	if os.IsNotExist(err) || (err == nil && !ours) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err := os.Remove(path); err != nil {
+		return false, err
+	}
+	if _, err := os.Stat(path + chainedSuffix); err == nil {
+		if err := os.Rename(path+chainedSuffix, path); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// checkPreCommit refuses commits on pr branches, which only mob update should write
+func checkPreCommit() error {
+	branch, err := repo.CurrentBranch()
+	if err != nil {
+		// No commits yet, so no mob branches either
+		return nil
+	}
+	if issue, ok := strings.CutPrefix(branch, "pr/"); ok {
+		return fmt.Errorf("'%s' is built by 'mob update'; commit on 'wip/%s' and run 'mob update' instead (or bypass with --no-verify)", branch, issue)
+	}
+	return nil
+}
+
+// checkReviewed returns an error unless the changes carried onto the issue's pr branch
+// had a completed review. A review of a later wip commit, or of the same changes before
+// a rebase, counts too.
+func checkReviewed(issue string, issueTracking tracking.IssueTracking) error {
+	review := issueTracking.Review
+	if review == nil {
+		return fmt.Errorf("#%s has no review; run 'mob review' on 'wip/%s' first", issue, issue)
+	}
+	if !review.Completed {
+		return fmt.Errorf("the last review of #%s wasn't completed; run 'mob review' on 'wip/%s' and check every item", issue, issue)
+	}
+
+	carried := issueTracking.LastMergedCommit
+	if carried == "" || carried == review.Commit || repo.IsAncestor(carried, review.Commit) {
+		return nil
+	}
+	if review.PatchID != "" {
+		if patchID, err := repo.PatchID(issueTracking.ForkPoint, carried); err == nil && patchID == review.PatchID {
+			return nil
+		}
+	}
+	return fmt.Errorf("'wip/%s' changed since its last review (reviewed %s, pr branch carries %s); run 'mob review' again",
+		issue, shortHash(review.Commit), shortHash(carried))
+}
+
+// checkPushedTip returns an error unless the pushed commit is the pr tip mob built from
+// reviewed changes, or its changes since the fork point are the reviewed ones. Commits put
+// on the pr branch by hand, such as cherry-picks or merges, don't pass.
+func checkPushedTip(issue string, issueTracking tracking.IssueTracking, pushed string) error {
+	if pushed == issueTracking.PRTip {
+		return checkReviewed(issue, issueTracking)
+	}
+	review := issueTracking.Review
+	if review != nil && review.Completed && review.PatchID != "" {
+		if patchID, err := repo.PatchID(issueTracking.ForkPoint, pushed); err == nil && patchID == review.PatchID {
+			return nil
+		}
+	}
+	return fmt.Errorf("'pr/%s' at %s isn't the commit 'mob update' built and its changes weren't reviewed; run 'mob review' and 'mob update' on 'wip/%s'",
+		issue, shortHash(pushed), issue)
+}
+
+// checkPrePush refuses pushes of pr branches whose changes weren't reviewed. Git passes
+// one "<local ref> <local hash> <remote ref> <remote hash>" line per ref on stdin.
+func checkPrePush() error {
+	var trackingData *tracking.TrackingData
+	var problems []string
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 4 {
+			continue
+		}
+		localHash, remoteRef := fields[1], fields[2]
+		issue, ok := strings.CutPrefix(remoteRef, "refs/heads/pr/")
+		// Deleting a branch needs no review
+		if !ok || localHash == git.ZeroHash {
+			continue
+		}
+
+		if trackingData == nil {
+			var err error
+			if trackingData, err = tracking.Load(); err != nil {
+				return fmt.Errorf("error loading tracking data: %w", err)
+			}
+		}
+		issueTracking, ok := trackingData.Issues[issue]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("#%s isn't tracked by mob; create 'pr/%s' with 'mob update'", issue, issue))
+			continue
+		}
+		if err := checkPushedTip(issue, issueTracking, localHash); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("push refused (bypass with --no-verify):\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+var hooksCmd = &cobra.Command{
+	Use:   "hooks",
+	Short: "Manage the git hooks that enforce mob's workflow",
+}
+
+var hooksInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Install the pre-commit and pre-push hooks",
+	Long: `Installs git hooks in the directory git runs hooks from (core.hooksPath if set):
+
+  pre-commit  refuses commits on pr/* branches, which 'mob update' builds
+  pre-push    refuses to push pr/<issue> unless it is the commit 'mob update' built from
+              changes that had a completed 'mob review'
+
+Hooks that are already there are renamed to <hook>.pre-mob and still run first.
+The hooks call this mob binary by its full path; install again if it moves.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := repo.HooksDir()
+		if err != nil {
+			return fmt.Errorf("error finding the hooks directory: %w", err)
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("error creating '%s': %w", dir, err)
+		}
+		mobPath, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("error finding the mob binary: %w", err)
+		}
+
+		for _, name := range hookNames {
+			chained, err := installHook(dir, name, mobPath)
+			if err != nil {
+				return fmt.Errorf("error installing the %s hook: %w", name, err)
+			}
+			if chained {
+				fmt.Printf("Installed %s (runs the existing %s%s first)\n", name, name, chainedSuffix)
+			} else {
+				fmt.Printf("Installed %s\n", name)
+			}
+		}
+		fmt.Printf("Hooks are in %s\n", dir)
+		return nil
+	},
+}
+
+var hooksUninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Remove mob's hooks and restore the ones they replaced",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := repo.HooksDir()
+		if err != nil {
+			return fmt.Errorf("error finding the hooks directory: %w", err)
+		}
+
+		for _, name := range hookNames {
+			removed, err := uninstallHook(dir, name)
+			if err != nil {
+				return fmt.Errorf("error removing the %s hook: %w", name, err)
+			}
+			if removed {
+				fmt.Printf("Removed %s\n", name)
+			}
+		}
+		return nil
+	},
+}
+
+var hooksRunCmd = &cobra.Command{
+	Use:          "run <hook> [args...]",
+	Short:        "Run the check of a hook; called by the installed hooks",
+	Hidden:       true,
+	Args:         cobra.MinimumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		switch args[0] {
+		case "pre-commit":
+			return checkPreCommit()
+		case "pre-push":
+			return checkPrePush()
+		default:
+			return fmt.Errorf("unknown hook '%s'", args[0])
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(hooksCmd)
+	hooksCmd.AddCommand(hooksInstallCmd)
+	hooksCmd.AddCommand(hooksUninstallCmd)
+	hooksCmd.AddCommand(hooksRunCmd)
+}
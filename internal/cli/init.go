@@ -3,11 +3,11 @@ package cli
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/huh"
-	"github.com/joaosaffran/mob/internal/errors"
-	"github.com/joaosaffran/mob/internal/git"
 	"github.com/joaosaffran/mob/internal/github"
+	"github.com/joaosaffran/mob/internal/journal"
 	"github.com/joaosaffran/mob/internal/tracking"
 	"github.com/joaosaffran/mob/internal/ui"
 	"github.com/spf13/cobra"
@@ -37,48 +37,114 @@ var initCmd = &cobra.Command{
 	Use:   "init [work]",
 	Short: "Create and checkout a new wip branch",
 	Args:  cobra.MaximumNArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		var work string
+	RunE:  runInit,
+}
+
+// runInit creates the wip branch for a piece of work and records its fork point
+func runInit(cmd *cobra.Command, args []string) error {
+	var work string
+
+	if len(args) == 0 {
+		// Fetch and display issues
+		issues, err := github.GetAssignedIssues()
+		if err != nil {
+			return fmt.Errorf("error fetching issues: %w", err)
+		}
+
+		issueNumber, err := selectIssue(issues)
+		if err != nil {
+			return fmt.Errorf("error selecting issue: %w", err)
+		}
 
-		if len(args) == 0 {
-			// Fetch and display issues
-			issues, err := github.GetAssignedIssues()
-			errors.ExitOnError(err, "Error fetching issues")
+		work = fmt.Sprintf("%d", issueNumber)
+	} else {
+		work = args[0]
+	}
+
+	// Sanitize work name for branch
+	work = strings.ReplaceAll(work, " ", "-")
+	branchName := fmt.Sprintf("wip/%s", work)
+	baseBranch, _ := cmd.Flags().GetString("base-branch")
+
+	if repo.BranchExists(branchName) {
+		return fmt.Errorf("branch '%s' already exists", branchName)
+	}
 
-			issueNumber, err := selectIssue(issues)
-			errors.ExitOnError(err, "Error selecting issue")
+	// Journal each step so an interrupted init can be recovered
+	j, err := journal.Begin("init", work, commandArgs(cmd, []string{work}))
+	if err != nil {
+		return err
+	}
 
-			work = fmt.Sprintf("%d", issueNumber)
-		} else {
-			work = args[0]
+	// Rollback function to restore state on failure
+	rollback := func(errMsg string) error {
+		fmt.Println("Rolling back changes...")
+		keepRunning()
+		if err := j.Undo(); err != nil {
+			return fmt.Errorf("%s (rollback failed: %v)", errMsg, err)
 		}
+		return fmt.Errorf("%s (changes rolled back)", errMsg)
+	}
 
-		// Sanitize work name for branch
-		work = strings.ReplaceAll(work, " ", "-")
-		branchName := fmt.Sprintf("wip/%s", work)
-		baseBranch, _ := cmd.Flags().GetString("base-branch")
+	if err := j.Record(branchName); err != nil {
+		return rollback(fmt.Sprintf("error writing journal: %v", err))
+	}
 
-		// If base branch is specified, checkout and pull latest
-		if baseBranch != "" {
-			errors.ExitOnErrorf(git.Checkout(baseBranch), "Error checking out base branch '%s'", baseBranch)
-			errors.ExitOnError(git.Pull(), "Error pulling latest updates")
+	// If base branch is specified, checkout and pull latest
+	if baseBranch != "" {
+		if err := j.Record(baseBranch); err != nil {
+			return rollback(fmt.Sprintf("error writing journal: %v", err))
+		}
+		if err := j.SetStep("checkout-base"); err != nil {
+			return rollback(fmt.Sprintf("error writing journal: %v", err))
+		}
+		if err := repo.Checkout(baseBranch); err != nil {
+			return rollback(fmt.Sprintf("error checking out base branch '%s': %v", baseBranch, err))
 		}
+		if err := j.SetStep("pull"); err != nil {
+			return rollback(fmt.Sprintf("error writing journal: %v", err))
+		}
+		if err := repo.Pull(); err != nil {
+			return rollback(fmt.Sprintf("error pulling latest updates: %v", err))
+		}
+	} else {
+		// Otherwise the branch we fork from is the base
+		baseBranch = j.OriginalBranch
+	}
 
-		// Get current commit hash as fork point before creating branch
-		forkPoint, err := git.GetCommitHash("HEAD")
-		errors.ExitOnError(err, "Error getting current commit")
+	// Get current commit hash as fork point before creating branch
+	forkPoint, err := repo.GetCommitHash("HEAD")
+	if err != nil {
+		return rollback(fmt.Sprintf("error getting current commit: %v", err))
+	}
 
-		// Create and checkout the wip branch
-		errors.ExitOnErrorf(git.CheckoutNewBranch(branchName), "Error creating branch '%s'", branchName)
+	// Create and checkout the wip branch
+	if err := j.SetStep("create-branch"); err != nil {
+		return rollback(fmt.Sprintf("error writing journal: %v", err))
+	}
+	if err := repo.CheckoutNewBranch(branchName); err != nil {
+		return rollback(fmt.Sprintf("error creating branch '%s': %v", branchName, err))
+	}
 
-		// Save fork point to tracking
-		trackingData, err := tracking.Load()
-		errors.ExitOnError(err, "Error loading tracking data")
+	// Save fork point to tracking
+	if err := j.SetStep("save-tracking"); err != nil {
+		return rollback(fmt.Sprintf("error writing journal: %v", err))
+	}
+	err = tracking.Update(func(trackingData *tracking.TrackingData) error {
 		trackingData.SetForkPoint(work, forkPoint)
-		errors.ExitOnError(trackingData.Save(), "Error saving tracking data")
+		trackingData.SetBaseBranch(work, baseBranch)
+		trackingData.AddEvent(work, tracking.Event{Type: tracking.EventCreated, At: time.Now()})
+		return nil
+	})
+	if err != nil {
+		return rollback(fmt.Sprintf("error saving tracking data: %v", err))
+	}
+	if err := j.Finish(); err != nil {
+		return fmt.Errorf("error removing journal: %w", err)
+	}
 
-		fmt.Printf("Created and switched to branch '%s'\n", branchName)
-	},
+	fmt.Printf("Created and switched to branch '%s'\n", branchName)
+	return nil
 }
 
 func init() {
@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// lfsHookInstalled reports whether a pre-push hook runs Git LFS, which uploads the
+// objects of pushed commits. A hook mob moved aside when installing its own still runs.
+func lfsHookInstalled() bool {
+	dir, err := repo.HooksDir()
+	if err != nil {
+		return false
+	}
+	for _, name := range []string{"pre-push", "pre-push" + chainedSuffix} {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err == nil && (bytes.Contains(content, []byte("git lfs")) || bytes.Contains(content, []byte("git-lfs"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// warnUnpushedLFS warns about Git LFS objects added between base and head that pushing
+// branch may not have uploaded. Only the local LFS store and hooks are checked, not the
+// remote, so the warnings say what couldn't be verified. The pr branch itself is already
+// pushed, so problems are only reported.
+func warnUnpushedLFS(branch, base, head string) {
+	files, err := repo.DiffFiles(base, head)
+	if err != nil {
+		fmt.Printf("Warning: couldn't check '%s' for Git LFS objects: %v\n", branch, err)
+		return
+	}
+
+	var added, missing []string
+	for _, f := range files {
+		if f.LFS == nil || f.LFS.New == nil || (f.LFS.Old != nil && f.LFS.Old.OID == f.LFS.New.OID) {
+			continue
+		}
+		added = append(added, f.Path())
+		if !repo.HasLFSObject(f.LFS.New.OID) {
+			missing = append(missing, f.Path())
+		}
+	}
+	if len(added) == 0 {
+		return
+	}
+
+	if !repo.LFSInstalled() {
+		fmt.Printf("Warning: '%s' adds %d Git LFS object(s), but git-lfs isn't installed, so this push couldn't upload them; check that the remote has them\n", branch, len(added))
+		return
+	}
+	if len(missing) > 0 {
+		fmt.Printf("Warning: the Git LFS objects of %s aren't in the local LFS store, so this push couldn't upload them; unless the remote already has them, run 'git lfs fetch' or re-add them with LFS\n", strings.Join(missing, ", "))
+	}
+	if !lfsHookInstalled() {
+		fmt.Printf("Warning: no Git LFS pre-push hook is installed, so mob couldn't verify that the LFS objects of '%s' were uploaded; run 'git lfs push origin %s' to make sure\n", branch, branch)
+	}
+}
@@ -0,0 +1,141 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/joaosaffran/mob/internal/config"
+	"github.com/joaosaffran/mob/internal/diff"
+	"github.com/joaosaffran/mob/internal/github"
+)
+
+// commitMessageData is what .mob/commit_template can use
+type commitMessageData struct {
+	// Issue is the issue id, such as "42"
+	Issue string
+	// Title is the GitHub issue title, or empty if it couldn't be fetched
+	Title string
+	// Subjects are the first lines of the wip commits being carried, oldest first
+	Subjects []string
+	// DiffStat summarizes the changes being carried, like git diff --stat
+	DiffStat string
+}
+
+// messageTemplateFuncs are the functions available to commit templates besides the built-in ones
+var messageTemplateFuncs = template.FuncMap{
+	"comment": commentLines,
+}
+
+// commentLines prefixes every line of text with '#', so the editor shows it but the
+// message leaves it out
+func commentLines(text string) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = "#"
+		} else {
+			lines[i] = "# " + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// commitMessageDataFor collects the template data for carrying commits (newest first) of
+// issue. The diff stat compares base, the commit the new pr commit goes on top of, with tip.
+func commitMessageDataFor(issue string, commits []string, base, tip string) (commitMessageData, error) {
+	data := commitMessageData{Issue: issue}
+
+	// Issues created from GitHub are numeric; the title is only a nicety
+	if issueNumber, _ := strconv.Atoi(issue); issueNumber > 0 {
+		if ghIssue, err := github.GetIssue(issueNumber); err != nil {
+			fmt.Printf("Warning: couldn't fetch the title of #%s: %v\n", issue, err)
+		} else {
+			data.Title = ghIssue.Title
+		}
+	}
+
+	for _, commit := range reversed(commits) {
+		message, err := repo.GetCommitMessage(commit)
+		if err != nil {
+			return data, fmt.Errorf("error getting commit message: %w", err)
+		}
+		subject, _, _ := strings.Cut(message, "\n")
+		data.Subjects = append(data.Subjects, subject)
+	}
+
+	files, err := repo.DiffFiles(base, tip)
+	if err != nil {
+		return data, fmt.Errorf("error getting diff stats: %w", err)
+	}
+	data.DiffStat = diff.Stat(files, diffStatWidth)
+	return data, nil
+}
+
+// renderCommitMessage fills in .mob/commit_template, or the default template, with data
+func renderCommitMessage(data commitMessageData) (string, error) {
+	source, err := config.LoadCommitTemplate()
+	if err != nil {
+		return "", fmt.Errorf("error loading commit template: %w", err)
+	}
+	tmpl, err := template.New("commit_template").Funcs(messageTemplateFuncs).Parse(source)
+	if err != nil {
+		return "", fmt.Errorf("error parsing commit template: %w", err)
+	}
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("error rendering commit template: %w", err)
+	}
+	return sb.String(), nil
+}
+
+// editMessage opens initial in the git editor and returns the message without comment
+// lines. help is added as a comment below it. An empty message is an error.
+func editMessage(initial, help string) (string, error) {
+	dir, err := os.MkdirTemp("", "mob-message-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+
+	// Keep git's file name so the editor picks commit message syntax
+	path := filepath.Join(dir, "COMMIT_EDITMSG")
+	content := strings.TrimRight(initial, "\n") + "\n\n" + commentLines(help) + "\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return "", err
+	}
+	if err := repo.EditFile(path); err != nil {
+		return "", fmt.Errorf("error editing the commit message: %w (pass one with -m instead)", err)
+	}
+
+	edited, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	message := cleanMessage(string(edited))
+	if message == "" {
+		return "", fmt.Errorf("aborting because the commit message is empty")
+	}
+	return message, nil
+}
+
+// cleanMessage drops comment lines and trailing whitespace, and collapses runs of blank
+// lines, like git commit's default cleanup
+func cleanMessage(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" && (len(lines) == 0 || lines[len(lines)-1] == "") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/joaosaffran/mob/internal/tracking"
+	"github.com/spf13/cobra"
+)
+
+// issueArg returns the issue given as argument, or the issue of the current wip or pr branch
+func issueArg(args []string) (string, error) {
+	if len(args) > 0 {
+		return args[0], nil
+	}
+
+	currentBranch, err := repo.CurrentBranch()
+	if err != nil {
+		return "", fmt.Errorf("error getting current branch: %w", err)
+	}
+	issue := strings.TrimPrefix(strings.TrimPrefix(currentBranch, "wip/"), "pr/")
+	if issue == currentBranch {
+		return "", fmt.Errorf("not on a wip or pr branch. Please pass the issue")
+	}
+	return issue, nil
+}
+
+// recordBreak records a pause or resume event, checking the issue is in the opposite state
+func recordBreak(issue string, eventType string) error {
+	return tracking.Update(func(trackingData *tracking.TrackingData) error {
+		issueTracking, ok := trackingData.Issues[issue]
+		if !ok {
+			return fmt.Errorf("issue #%s is not tracked", issue)
+		}
+		paused := issueTracking.IsPaused()
+		if eventType == tracking.EventPause && paused {
+			return fmt.Errorf("issue #%s is already paused", issue)
+		}
+		if eventType == tracking.EventResume && !paused {
+			return fmt.Errorf("issue #%s is not paused", issue)
+		}
+		trackingData.AddEvent(issue, tracking.Event{Type: eventType, At: time.Now()})
+		return nil
+	})
+}
+
+var pauseCmd = &cobra.Command{
+	Use:   "pause [issue]",
+	Short: "Mark the start of a break on an issue",
+	Long: `Stops counting active time for an issue until 'mob resume'.
+Without an issue, the issue of the current wip or pr branch is used.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		issue, err := issueArg(args)
+		if err != nil {
+			return err
+		}
+		if err := recordBreak(issue, tracking.EventPause); err != nil {
+			return err
+		}
+		fmt.Printf("Paused #%s\n", issue)
+		return nil
+	},
+}
+
+var resumeCmd = &cobra.Command{
+	Use:   "resume [issue]",
+	Short: "Mark the end of a break on an issue",
+	Long: `Starts counting active time for a paused issue again.
+Without an issue, the issue of the current wip or pr branch is used.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		issue, err := issueArg(args)
+		if err != nil {
+			return err
+		}
+		if err := recordBreak(issue, tracking.EventResume); err != nil {
+			return err
+		}
+		fmt.Printf("Resumed #%s\n", issue)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pauseCmd)
+	rootCmd.AddCommand(resumeCmd)
+}
@@ -0,0 +1,151 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/joaosaffran/mob/internal/diff"
+	"github.com/joaosaffran/mob/internal/tracking"
+)
+
+// diffStatWidth is the width diffstats are laid out in, like git outside a terminal
+const diffStatWidth = 80
+
+// planCommit is a commit that an update would carry onto the pr branch
+type planCommit struct {
+	Hash    string `json:"hash"`
+	Subject string `json:"subject"`
+}
+
+// updatePlan describes what 'mob update' would do
+type updatePlan struct {
+	Issue          string       `json:"issue"`
+	Mode           string       `json:"mode"`
+	WipBranch      string       `json:"wip_branch"`
+	PRBranch       string       `json:"pr_branch"`
+	PRBranchExists bool         `json:"pr_branch_exists"`
+	StartPoint     string       `json:"start_point"`
+	Commits        []planCommit `json:"commits"`
+	DiffBase       string       `json:"diff_base"`
+	DiffStat       string       `json:"diffstat"`
+	PushRemote     string       `json:"push_remote"`
+	PushBranch     string       `json:"push_branch"`
+	ForcePush      bool         `json:"force_push"`
+}
+
+// carriedCommits returns the wip commits (newest first) an update builds the pr branch from:
+// the unmerged ones, or every commit since the fork point in squash-all mode. Nothing is
+// carried when no commit is new.
+func carriedCommits(mode string, allCommits, unmergedCommits []string) []string {
+	if len(unmergedCommits) == 0 {
+		return nil
+	}
+	if mode == tracking.ModeSquashAll {
+		return allCommits
+	}
+	return unmergedCommits
+}
+
+// buildUpdatePlan gathers the plan for an update that carries commits (newest first)
+// without changing the repository
+func buildUpdatePlan(issue, mode, forkPoint string, commits []string) (*updatePlan, error) {
+	plan := &updatePlan{
+		Issue:      issue,
+		Mode:       mode,
+		WipBranch:  fmt.Sprintf("wip/%s", issue),
+		PRBranch:   fmt.Sprintf("pr/%s", issue),
+		Commits:    []planCommit{},
+		PushRemote: "origin",
+		ForcePush:  mode == tracking.ModeSquashAll,
+	}
+	plan.PushBranch = plan.PRBranch
+
+	// The pr branch is reused if it exists, otherwise created from the fork point.
+	// squash-all always starts over from the fork point.
+	plan.PRBranchExists = repo.BranchExists(plan.PRBranch)
+	plan.StartPoint = forkPoint
+	if plan.PRBranchExists && mode != tracking.ModeSquashAll {
+		hash, err := repo.GetCommitHash(plan.PRBranch)
+		if err != nil {
+			return nil, fmt.Errorf("error getting commit hash: %w", err)
+		}
+		plan.StartPoint = hash
+	}
+
+	for _, commit := range commits {
+		message, err := repo.GetCommitMessage(commit)
+		if err != nil {
+			return nil, fmt.Errorf("error getting commit message: %w", err)
+		}
+		subject, _, _ := strings.Cut(message, "\n")
+		plan.Commits = append(plan.Commits, planCommit{Hash: commit, Subject: subject})
+	}
+
+	// Compare against what the remote currently has
+	plan.DiffBase = forkPoint
+	remotePR := fmt.Sprintf("%s/%s", plan.PushRemote, plan.PRBranch)
+	if repo.BranchExists(remotePR) {
+		plan.DiffBase = remotePR
+	}
+	files, err := repo.DiffFiles(plan.DiffBase, plan.WipBranch)
+	if err != nil {
+		return nil, fmt.Errorf("error getting diff stats: %w", err)
+	}
+	plan.DiffStat = diff.Stat(files, diffStatWidth)
+
+	return plan, nil
+}
+
+// print writes the plan in human-readable form
+func (p *updatePlan) print() {
+	fmt.Println("Dry run: no changes will be made")
+	fmt.Println()
+
+	if p.PRBranchExists {
+		fmt.Printf("Branch:   reuse '%s' (at %s)\n", p.PRBranch, shortHash(p.StartPoint))
+	} else {
+		fmt.Printf("Branch:   create '%s' from %s\n", p.PRBranch, shortHash(p.StartPoint))
+	}
+	fmt.Printf("Mode:     %s\n", p.Mode)
+
+	if p.Mode == tracking.ModeSquashAll {
+		fmt.Printf("Commits:  %d since the fork point\n", len(p.Commits))
+	} else {
+		fmt.Printf("Commits:  %d unmerged\n", len(p.Commits))
+	}
+	for _, c := range p.Commits {
+		fmt.Printf("  %s %s\n", shortHash(c.Hash), c.Subject)
+	}
+
+	fmt.Printf("Diffstat: against %s\n", p.DiffBase)
+	if p.DiffStat == "" {
+		fmt.Println("  (no changes)")
+	} else {
+		for _, line := range strings.Split(p.DiffStat, "\n") {
+			fmt.Printf("  %s\n", strings.TrimSpace(line))
+		}
+	}
+
+	push := fmt.Sprintf("%s %s", p.PushRemote, p.PushBranch)
+	if p.ForcePush {
+		push += " (force with lease)"
+	}
+	fmt.Printf("Push:     %s\n", push)
+}
+
+// printJSON writes the plan as indented JSON
+func (p *updatePlan) printJSON() error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(p)
+}
+
+// shortHash abbreviates a commit hash for display
+func shortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
@@ -0,0 +1,171 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/joaosaffran/mob/internal/config"
+	"github.com/joaosaffran/mob/internal/diff"
+	"github.com/joaosaffran/mob/internal/github"
+	"github.com/joaosaffran/mob/internal/tracking"
+	"github.com/spf13/cobra"
+)
+
+// buildPRBody renders the pull request description from the diffstat and checklist state
+func buildPRBody(issueNumber int, diffStat string, items []tracking.ReviewItem) string {
+	var sb strings.Builder
+
+	if issueNumber > 0 {
+		sb.WriteString(fmt.Sprintf("Fixes #%d\n\n", issueNumber))
+	}
+
+	sb.WriteString("## Changes\n\n")
+	sb.WriteString("```\n")
+	sb.WriteString(diffStat)
+	sb.WriteString("\n```\n\n")
+
+	sb.WriteString("## Checklist\n\n")
+	for _, item := range items {
+		mark := " "
+		if item.Checked {
+			mark = "x"
+		}
+		sb.WriteString(fmt.Sprintf("- [%s] %s\n", mark, item.Description))
+	}
+
+	return sb.String()
+}
+
+// checklistState returns the checklist recorded by the last review, or the unchecked configured checklist
+func checklistState(issueTracking tracking.IssueTracking) ([]tracking.ReviewItem, error) {
+	if issueTracking.Review != nil {
+		return issueTracking.Review.Items, nil
+	}
+
+	checklist, err := config.LoadChecklist()
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]tracking.ReviewItem, len(checklist.Items))
+	for i, item := range checklist.Items {
+		items[i] = tracking.ReviewItem{Description: item.Description}
+	}
+	return items, nil
+}
+
+var prCmd = &cobra.Command{
+	Use:   "pr",
+	Short: "Create or update the pull request for pr/<issue>",
+	Long: `Opens a GitHub pull request from pr/<issue> into the issue's base branch.
+If a pull request is already open for the branch, its title and description are updated instead.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Get current branch
+		currentBranch, err := repo.CurrentBranch()
+		if err != nil {
+			return fmt.Errorf("error getting current branch: %w", err)
+		}
+
+		// Verify we're on a wip branch
+		if !strings.HasPrefix(currentBranch, "wip/") {
+			return fmt.Errorf("not on a wip branch. Please checkout a wip/<issue> branch first")
+		}
+
+		// Extract issue from branch name
+		issue := strings.TrimPrefix(currentBranch, "wip/")
+		prBranch := fmt.Sprintf("pr/%s", issue)
+
+		if !repo.BranchExists(prBranch) {
+			return fmt.Errorf("branch '%s' does not exist. Run 'mob update' first", prBranch)
+		}
+
+		// Load tracking data
+		trackingData, err := tracking.Load()
+		if err != nil {
+			return fmt.Errorf("error loading tracking data: %w", err)
+		}
+		issueTracking := trackingData.GetIssueTracking(issue)
+
+		forkPoint := issueTracking.ForkPoint
+		if forkPoint == "" {
+			return fmt.Errorf("no fork point found for this issue. Was this branch created with 'mob init'?")
+		}
+
+		// Resolve the base branch: flag, then tracking, then the repository default
+		baseBranch, _ := cmd.Flags().GetString("base")
+		if baseBranch == "" {
+			baseBranch = issueTracking.BaseBranch
+		}
+		if baseBranch == "" {
+			baseBranch, err = github.GetDefaultBranch()
+			if err != nil {
+				return fmt.Errorf("error getting default branch: %w", err)
+			}
+		}
+		baseBranch = strings.TrimPrefix(baseBranch, "origin/")
+
+		// Issues created from GitHub are numeric and get linked from the PR
+		issueNumber, _ := strconv.Atoi(issue)
+
+		title, _ := cmd.Flags().GetString("title")
+		if title == "" {
+			title = issue
+			if issueNumber > 0 {
+				ghIssue, err := github.GetIssue(issueNumber)
+				if err != nil {
+					return fmt.Errorf("error fetching issue: %w", err)
+				}
+				title = ghIssue.Title
+			}
+		}
+
+		files, err := repo.DiffFiles(forkPoint, prBranch)
+		if err != nil {
+			return fmt.Errorf("error getting diff stats: %w", err)
+		}
+		diffStat := diff.Stat(files, diffStatWidth)
+
+		items, err := checklistState(issueTracking)
+		if err != nil {
+			return fmt.Errorf("error loading checklist: %w", err)
+		}
+
+		body := buildPRBody(issueNumber, diffStat, items)
+
+		// Find an existing pull request for the branch
+		pr, err := github.FindPullRequest(prBranch)
+		if err != nil {
+			return fmt.Errorf("error looking up pull request: %w", err)
+		}
+
+		if pr != nil {
+			if err := github.EditPullRequest(pr.Number, baseBranch, title, body); err != nil {
+				return fmt.Errorf("error updating pull request: %w", err)
+			}
+			fmt.Printf("Updated pull request #%d: %s\n", pr.Number, pr.URL)
+		} else {
+			pr, err = github.CreatePullRequest(baseBranch, prBranch, title, body)
+			if err != nil {
+				return fmt.Errorf("error creating pull request: %w", err)
+			}
+			fmt.Printf("Created pull request #%d: %s\n", pr.Number, pr.URL)
+		}
+
+		err = tracking.Update(func(trackingData *tracking.TrackingData) error {
+			trackingData.SetPRNumber(issue, pr.Number)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("error saving tracking data: %w", err)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(prCmd)
+	prCmd.Flags().StringP("base", "b", "", "Base branch for the pull request (defaults to the issue's base branch)")
+	prCmd.Flags().StringP("title", "t", "", "Pull request title (defaults to the issue title)")
+}
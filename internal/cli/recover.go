@@ -0,0 +1,151 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/huh"
+	"github.com/joaosaffran/mob/internal/journal"
+	"github.com/joaosaffran/mob/internal/ui"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Ways to recover an interrupted operation
+const (
+	recoverFinish = iota
+	recoverUndo
+)
+
+// commandArgs rebuilds the command line of a command from its path, changed flags and arguments
+func commandArgs(cmd *cobra.Command, args []string) []string {
+	result := strings.Fields(cmd.CommandPath())[1:]
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		result = append(result, fmt.Sprintf("--%s=%s", f.Name, f.Value.String()))
+	})
+	return append(result, args...)
+}
+
+// finishInterrupted completes an interrupted operation. Once an operation reached its
+// push step only the push remains; before that it is undone and run again from the start.
+func finishInterrupted(j *journal.Journal) error {
+	if j.Push != nil {
+		var err error
+		if j.Push.Force {
+			err = repo.PushForceWithLease(j.Push.Remote, j.Push.Branch)
+		} else {
+			err = repo.PushSetUpstream(j.Push.Remote, j.Push.Branch)
+		}
+		if err != nil {
+			return fmt.Errorf("error pushing to remote: %w", err)
+		}
+
+		if currentBranch, _ := repo.CurrentBranch(); currentBranch != j.OriginalBranch {
+			if err := repo.Checkout(j.OriginalBranch); err != nil {
+				return fmt.Errorf("error switching back to '%s': %w", j.OriginalBranch, err)
+			}
+		}
+		return j.Finish()
+	}
+
+	if err := j.Undo(); err != nil {
+		return fmt.Errorf("error undoing interrupted '%s': %w", j.Operation, err)
+	}
+
+	fmt.Printf("Running 'mob %s' again...\n", strings.Join(j.Args, " "))
+	return rerun(j)
+}
+
+// operations are the journaled operations, by the name they are journaled under
+var operations = map[string]func(cmd *cobra.Command, args []string) error{
+	"init":   runInit,
+	"update": runUpdate,
+	"sync":   runSync,
+	"finish": runFinish,
+}
+
+// rerun runs a journaled operation again with the flags and arguments it was started with
+func rerun(j *journal.Journal) error {
+	run, ok := operations[j.Operation]
+	if !ok || len(j.Args) == 0 {
+		return fmt.Errorf("don't know how to run '%s' again; use --undo instead", j.Operation)
+	}
+	cmd, args, err := rootCmd.Find(j.Args)
+	if err != nil {
+		return fmt.Errorf("error reading the arguments of '%s': %w", j.Operation, err)
+	}
+	if err := cmd.ParseFlags(args); err != nil {
+		return fmt.Errorf("error reading the arguments of '%s': %w", j.Operation, err)
+	}
+	return run(cmd, cmd.Flags().Args())
+}
+
+var recoverCmd = &cobra.Command{
+	Use:   "recover",
+	Short: "Finish or undo an interrupted operation",
+	Long: `Reads the operation journal left in .mob/ by an interrupted init, update or sync
+and either finishes the operation or restores the branches and tracking data it started from.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		j, err := journal.Load()
+		if err != nil {
+			return fmt.Errorf("error reading journal: %w", err)
+		}
+		if j == nil {
+			fmt.Println("No interrupted operation found")
+			return nil
+		}
+
+		fmt.Printf("Interrupted: mob %s\n", strings.Join(j.Args, " "))
+		fmt.Printf("Started:     %s on '%s'\n", j.StartedAt.Format("2006-01-02 15:04:05"), j.OriginalBranch)
+		fmt.Printf("Last step:   %s\n", j.Step)
+		for branch, commit := range j.Refs {
+			if commit == "" {
+				fmt.Printf("  %s (did not exist)\n", branch)
+			} else {
+				fmt.Printf("  %s was at %s\n", branch, shortHash(commit))
+			}
+		}
+
+		finish, _ := cmd.Flags().GetBool("finish")
+		undo, _ := cmd.Flags().GetBool("undo")
+		if finish && undo {
+			return fmt.Errorf("--finish and --undo cannot be used together")
+		}
+
+		choice := recoverUndo
+		switch {
+		case finish:
+			choice = recoverFinish
+		case undo:
+			choice = recoverUndo
+		default:
+			options := []huh.Option[int]{
+				huh.NewOption(fmt.Sprintf("Finish the %s", j.Operation), recoverFinish),
+				huh.NewOption("Undo it and restore the original state", recoverUndo),
+			}
+			choice, err = ui.ShowForm(options, "How do you want to recover?")
+			if err != nil {
+				return fmt.Errorf("error selecting recovery: %w", err)
+			}
+		}
+
+		if choice == recoverFinish {
+			return finishInterrupted(j)
+		}
+
+		if err := j.Undo(); err != nil {
+			return fmt.Errorf("error undoing interrupted '%s': %w", j.Operation, err)
+		}
+		if j.Push != nil {
+			fmt.Printf("Note: '%s' may already have been pushed to %s\n", j.Push.Branch, j.Push.Remote)
+		}
+		fmt.Printf("Restored the state from before 'mob %s'\n", j.Operation)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(recoverCmd)
+	recoverCmd.Flags().Bool("finish", false, "Finish the interrupted operation without prompting")
+	recoverCmd.Flags().Bool("undo", false, "Undo the interrupted operation without prompting")
+}
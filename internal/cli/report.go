@@ -0,0 +1,201 @@
+package cli
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/joaosaffran/mob/internal/tracking"
+	"github.com/spf13/cobra"
+)
+
+// reportFormats are the values accepted by --format
+var reportFormats = []string{"table", "csv", "json"}
+
+// timeReport is the time spent on one issue
+type timeReport struct {
+	Issue         string     `json:"issue"`
+	State         string     `json:"state"`
+	ActiveSeconds int64      `json:"active_seconds"`
+	Reviews       int        `json:"reviews"`
+	ReviewSeconds int64      `json:"review_seconds"`
+	Updates       int        `json:"updates"`
+	Started       *time.Time `json:"started,omitempty"`
+	LastEvent     *time.Time `json:"last_event,omitempty"`
+}
+
+// parseSince parses a --since value: a date, an RFC 3339 time, or a duration back from
+// now such as 36h or 7d
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since '%s' (expected a date like 2006-01-02, an RFC 3339 time or a duration like 36h or 7d)", value)
+}
+
+// buildTimeReport summarizes the events of an issue between since and until.
+// It returns nil if nothing happened on the issue in that time.
+// others are the times other issues became active, which ends a stretch of work on this one.
+func buildTimeReport(issue, state string, issueTracking tracking.IssueTracking, others []time.Time, since, until time.Time) *timeReport {
+	report := &timeReport{
+		Issue:         issue,
+		State:         state,
+		ActiveSeconds: int64(issueTracking.ActiveTime(since, until, others).Seconds()),
+	}
+
+	for i, e := range issueTracking.Events {
+		if i == 0 {
+			report.Started = &issueTracking.Events[0].At
+		}
+		report.LastEvent = &issueTracking.Events[i].At
+		if e.At.Before(since) {
+			continue
+		}
+		switch e.Type {
+		case tracking.EventReview:
+			report.Reviews++
+			report.ReviewSeconds += e.DurationSeconds
+		case tracking.EventUpdate:
+			report.Updates++
+		}
+	}
+
+	if report.ActiveSeconds == 0 && report.Reviews == 0 && report.Updates == 0 {
+		return nil
+	}
+	return report
+}
+
+// formatSeconds renders a number of seconds as hours and minutes
+func formatSeconds(seconds int64) string {
+	d := (time.Duration(seconds) * time.Second).Round(time.Minute)
+	return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+}
+
+// printTimeReports writes the reports in the given format
+func printTimeReports(reports []timeReport, format string) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(reports)
+
+	case "csv":
+		w := csv.NewWriter(os.Stdout)
+		w.Write([]string{"issue", "state", "active_seconds", "reviews", "review_seconds", "updates", "started", "last_event"})
+		for _, r := range reports {
+			started, last := "", ""
+			if r.Started != nil {
+				started = r.Started.Format(time.RFC3339)
+			}
+			if r.LastEvent != nil {
+				last = r.LastEvent.Format(time.RFC3339)
+			}
+			w.Write([]string{
+				r.Issue, r.State, strconv.FormatInt(r.ActiveSeconds, 10), strconv.Itoa(r.Reviews),
+				strconv.FormatInt(r.ReviewSeconds, 10), strconv.Itoa(r.Updates), started, last,
+			})
+		}
+		w.Flush()
+		return w.Error()
+
+	default:
+		if len(reports) == 0 {
+			fmt.Println("No time recorded")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ISSUE\tSTATE\tACTIVE\tREVIEWS\tREVIEW TIME\tUPDATES")
+		var total int64
+		for _, r := range reports {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%d\n",
+				r.Issue, r.State, formatSeconds(r.ActiveSeconds), r.Reviews, formatSeconds(r.ReviewSeconds), r.Updates)
+			total += r.ActiveSeconds
+		}
+		fmt.Fprintf(w, "TOTAL\t\t%s\t\t\t\n", formatSeconds(total))
+		return w.Flush()
+	}
+}
+
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Report on tracked issues",
+}
+
+var reportTimeCmd = &cobra.Command{
+	Use:   "time",
+	Short: "Report the active time spent on each issue",
+	Long: `Totals the active time of every issue. Every mob event on an issue (init, review,
+update, resume) counts as work until the issue's next event, for at most 4 hours or the
+length of the review. Work on an issue stops counting as soon as another issue has an
+event, and during breaks marked with 'mob pause' and 'mob resume', so time is never
+counted for two issues at once. With --since only time after that point is counted.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sinceValue, _ := cmd.Flags().GetString("since")
+		format, _ := cmd.Flags().GetString("format")
+		valid := false
+		for _, f := range reportFormats {
+			valid = valid || f == format
+		}
+		if !valid {
+			return fmt.Errorf("invalid format '%s' (expected one of %v)", format, reportFormats)
+		}
+
+		now := time.Now()
+		since, err := parseSince(sinceValue, now)
+		if err != nil {
+			return err
+		}
+
+		trackingData, err := tracking.Load()
+		if err != nil {
+			return fmt.Errorf("error loading tracking data: %w", err)
+		}
+
+		reports := []timeReport{}
+		for issue, issueTracking := range trackingData.Issues {
+			state := "active"
+			if issueTracking.IsPaused() {
+				state = "paused"
+			}
+			if r := buildTimeReport(issue, state, issueTracking, trackingData.ActivityTimes(issue), since, now); r != nil {
+				reports = append(reports, *r)
+			}
+		}
+		for issue, archived := range trackingData.Archive {
+			if r := buildTimeReport(issue, "finished", archived.IssueTracking, trackingData.ActivityTimes(issue), since, now); r != nil {
+				reports = append(reports, *r)
+			}
+		}
+		sort.Slice(reports, func(i, j int) bool { return reports[i].Issue < reports[j].Issue })
+
+		return printTimeReports(reports, format)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(reportCmd)
+	reportCmd.AddCommand(reportTimeCmd)
+	reportTimeCmd.Flags().String("since", "", "Only count time after this date (2006-01-02), time (RFC 3339) or duration ago (36h, 7d)")
+	reportTimeCmd.Flags().String("format", "table", "Output format: table, csv or json")
+}
@@ -3,9 +3,10 @@ package cli
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/joaosaffran/mob/internal/config"
-	"github.com/joaosaffran/mob/internal/git"
+	"github.com/joaosaffran/mob/internal/diff"
 	"github.com/joaosaffran/mob/internal/tracking"
 	"github.com/joaosaffran/mob/internal/ui"
 	"github.com/spf13/cobra"
@@ -15,10 +16,14 @@ var reviewCmd = &cobra.Command{
 	Use:   "review",
 	Short: "Review changes before updating PR",
 	Long: `Shows a diff of all changes and a checklist to verify before updating.
-All checklist items must be checked before update is allowed.`,
+All checklist items must be checked before update is allowed.
+
+With --worktree the diff also shows staged and unstaged changes (and untracked
+files with --untracked), each labeled with where it lives. Press w in the
+review to switch between the two views.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// Get current branch
-		currentBranch, err := git.CurrentBranch()
+		currentBranch, err := repo.CurrentBranch()
 		if err != nil {
 			return fmt.Errorf("error getting current branch: %w", err)
 		}
@@ -45,20 +50,24 @@ All checklist items must be checked before update is allowed.`,
 		}
 
 		// Get diff
-		diff, err := git.Diff(forkPoint, wipBranch)
+		files, err := repo.DiffFiles(forkPoint, wipBranch)
 		if err != nil {
 			return fmt.Errorf("error getting diff: %w", err)
 		}
-
-		if diff == "" {
-			fmt.Println("No changes to review")
-			return nil
+		showWorktree, _ := cmd.Flags().GetBool("worktree")
+		untracked, _ := cmd.Flags().GetBool("untracked")
+		worktree, err := worktreeFiles(files, untracked)
+		if err != nil {
+			return fmt.Errorf("error getting uncommitted changes: %w", err)
 		}
 
-		// Get diff stats
-		diffStat, err := git.DiffStat(forkPoint, wipBranch)
-		if err != nil {
-			diffStat = "Unable to get diff stats"
+		if len(files) == 0 && (!showWorktree || len(worktree) == 0) {
+			if len(worktree) > len(files) {
+				fmt.Println("No committed changes to review; pass --worktree to review uncommitted ones")
+			} else {
+				fmt.Println("No changes to review")
+			}
+			return nil
 		}
 
 		// Load checklist
@@ -74,13 +83,41 @@ All checklist items must be checked before update is allowed.`,
 		}
 
 		// Run review UI
-		completed, err := ui.RunReview(diff, diffStat, issue, uiItems)
+		startedAt := time.Now()
+		changes := ui.ReviewChanges{Committed: files, Worktree: worktree, ShowWorktree: showWorktree}
+		result, err := ui.RunReview(changes, issue, uiItems)
 		if err != nil {
 			return fmt.Errorf("error running review UI: %w", err)
 		}
 
+		// Record the checklist state for the reviewed commit
+		reviewedCommit, err := repo.GetCommitHash(wipBranch)
+		if err != nil {
+			return fmt.Errorf("error getting commit hash: %w", err)
+		}
+		patchID, err := repo.PatchID(forkPoint, reviewedCommit)
+		if err != nil {
+			return fmt.Errorf("error computing patch ID: %w", err)
+		}
+		review := tracking.Review{Commit: reviewedCommit, PatchID: patchID, Completed: result.Completed}
+		for _, item := range result.Items {
+			review.Items = append(review.Items, tracking.ReviewItem{Description: item.Description, Checked: item.Checked})
+		}
+		err = tracking.Update(func(trackingData *tracking.TrackingData) error {
+			trackingData.SetReview(issue, review)
+			trackingData.AddEvent(issue, tracking.Event{
+				Type:            tracking.EventReview,
+				At:              startedAt,
+				DurationSeconds: int64(time.Since(startedAt).Seconds()),
+			})
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("error saving tracking data: %w", err)
+		}
+
 		// Check if review is complete
-		if completed {
+		if result.Completed {
 			fmt.Println("\n✓ Review complete! You can now run 'mob update' to push changes.")
 		} else {
 			fmt.Println("\n✗ Review incomplete. Please check all items before updating.")
@@ -90,6 +127,40 @@ All checklist items must be checked before update is allowed.`,
 	},
 }
 
+// worktreeFiles returns the committed changes labeled as such, followed by the staged,
+// unstaged and, if asked for, untracked changes
+func worktreeFiles(committed []*diff.File, untracked bool) ([]*diff.File, error) {
+	var files []*diff.File
+	for _, f := range committed {
+		// Copy so the committed view stays unlabeled
+		labeled := *f
+		labeled.Source = diff.Committed
+		files = append(files, &labeled)
+	}
+
+	staged, err := repo.DiffStaged()
+	if err != nil {
+		return nil, err
+	}
+	unstaged, err := repo.DiffUnstaged()
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, diff.SetSource(staged, diff.Staged)...)
+	files = append(files, diff.SetSource(unstaged, diff.Unstaged)...)
+
+	if untracked {
+		added, err := repo.DiffUntracked()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, diff.SetSource(added, diff.Untracked)...)
+	}
+	return files, nil
+}
+
 func init() {
 	rootCmd.AddCommand(reviewCmd)
+	reviewCmd.Flags().Bool("worktree", false, "Include staged and unstaged changes in the review")
+	reviewCmd.Flags().Bool("untracked", false, "Also include untracked files in the working tree view")
 }
@@ -0,0 +1,252 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/charmbracelet/huh"
+	"github.com/joaosaffran/mob/internal/github"
+	"github.com/joaosaffran/mob/internal/tracking"
+	"github.com/joaosaffran/mob/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// branchStatus tells where a branch exists
+type branchStatus struct {
+	Local  bool `json:"local"`
+	Remote bool `json:"remote"`
+}
+
+// String renders the branch locations for the table view
+func (b branchStatus) String() string {
+	switch {
+	case b.Local && b.Remote:
+		return "local+remote"
+	case b.Local:
+		return "local"
+	case b.Remote:
+		return "remote"
+	default:
+		return "-"
+	}
+}
+
+// aheadBehind holds commit counts relative to another ref
+type aheadBehind struct {
+	Ref    string `json:"ref"`
+	Ahead  int    `json:"ahead"`
+	Behind int    `json:"behind"`
+}
+
+// String renders the counts for the table view
+func (a *aheadBehind) String() string {
+	if a == nil {
+		return "-"
+	}
+	return fmt.Sprintf("+%d/-%d", a.Ahead, a.Behind)
+}
+
+// issueStatus summarizes a tracked issue
+type issueStatus struct {
+	Issue      string       `json:"issue"`
+	BaseBranch string       `json:"base_branch,omitempty"`
+	Mode       string       `json:"mode"`
+	Wip        branchStatus `json:"wip"`
+	PR         branchStatus `json:"pr"`
+	VsBase     *aheadBehind `json:"vs_base,omitempty"`
+	VsPR       *aheadBehind `json:"vs_pr,omitempty"`
+	VsRemotePR *aheadBehind `json:"vs_remote_pr,omitempty"`
+	Unmerged   int          `json:"unmerged"`
+	UpdatedAt  *time.Time   `json:"updated_at,omitempty"`
+	PRNumber   int          `json:"pr_number,omitempty"`
+	PRState    string       `json:"pr_state,omitempty"`
+}
+
+// collectIssueStatus gathers the status of one issue. Git errors only leave fields empty,
+// so one broken issue doesn't hide the others.
+func collectIssueStatus(trackingData *tracking.TrackingData, issue string, withPR bool) issueStatus {
+	issueTracking := trackingData.GetIssueTracking(issue)
+	wipBranch := fmt.Sprintf("wip/%s", issue)
+	prBranch := fmt.Sprintf("pr/%s", issue)
+
+	status := issueStatus{
+		Issue:      issue,
+		BaseBranch: issueTracking.BaseBranch,
+		Mode:       trackingData.GetMode(issue),
+		// Fully qualified refs so local and remote branches aren't confused
+		Wip: branchStatus{
+			Local:  repo.BranchExists("refs/heads/" + wipBranch),
+			Remote: repo.BranchExists("refs/remotes/origin/" + wipBranch),
+		},
+		PR: branchStatus{
+			Local:  repo.BranchExists("refs/heads/" + prBranch),
+			Remote: repo.BranchExists("refs/remotes/origin/" + prBranch),
+		},
+		PRNumber: issueTracking.PRNumber,
+	}
+	if !issueTracking.UpdatedAt.IsZero() {
+		status.UpdatedAt = &issueTracking.UpdatedAt
+	}
+
+	if status.Wip.Local {
+		// Compare against the remote base when we have it, like sync does
+		if base := issueTracking.BaseBranch; base != "" {
+			baseRef := base
+			if repo.BranchExists("refs/remotes/origin/" + base) {
+				baseRef = "origin/" + base
+			}
+			if ahead, behind, err := repo.AheadBehind(baseRef, wipBranch); err == nil {
+				status.VsBase = &aheadBehind{Ref: baseRef, Ahead: ahead, Behind: behind}
+			}
+		}
+
+		// Compare against the local pr branch, or the remote one when there is no local copy
+		prRef := ""
+		switch {
+		case status.PR.Local:
+			prRef = prBranch
+		case status.PR.Remote:
+			prRef = "origin/" + prBranch
+		}
+		if prRef != "" {
+			if ahead, behind, err := repo.AheadBehind(prRef, wipBranch); err == nil {
+				status.VsPR = &aheadBehind{Ref: prRef, Ahead: ahead, Behind: behind}
+			}
+		}
+
+		if issueTracking.ForkPoint != "" {
+			if commits, err := repo.GetCommitsBetween(issueTracking.ForkPoint, wipBranch); err == nil {
+				status.Unmerged = len(trackingData.GetUnmergedCommits(issue, commits, repo.PatchIDs))
+			}
+		}
+	}
+
+	if status.PR.Local && status.PR.Remote {
+		remotePR := "origin/" + prBranch
+		if ahead, behind, err := repo.AheadBehind(remotePR, prBranch); err == nil {
+			status.VsRemotePR = &aheadBehind{Ref: remotePR, Ahead: ahead, Behind: behind}
+		}
+	}
+
+	if withPR && status.PR.Remote {
+		var pr *github.PullRequest
+		var err error
+		if status.PRNumber > 0 {
+			pr, err = github.GetPullRequest(status.PRNumber)
+		} else {
+			pr, err = github.GetPullRequestForBranch(prBranch)
+		}
+		if err == nil {
+			status.PRNumber = pr.Number
+			status.PRState = pr.State
+		}
+	}
+
+	return status
+}
+
+// printStatusTable writes the statuses as an aligned table
+func printStatusTable(statuses []issueStatus) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ISSUE\tWIP\tPR\tVS BASE\tVS PR\tPR VS REMOTE\tUNMERGED\tUPDATED\tPULL REQUEST")
+	for _, s := range statuses {
+		updated := "-"
+		if s.UpdatedAt != nil {
+			updated = s.UpdatedAt.Format("2006-01-02 15:04")
+		}
+		pr := "-"
+		if s.PRNumber > 0 {
+			pr = fmt.Sprintf("#%d %s", s.PRNumber, strings.ToLower(s.PRState))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			s.Issue, s.Wip, s.PR, s.VsBase, s.VsPR, s.VsRemotePR, s.Unmerged, updated, strings.TrimSpace(pr))
+	}
+	w.Flush()
+}
+
+// selectIssueStatus lets the user pick one of the issues and returns its index
+func selectIssueStatus(statuses []issueStatus) (int, error) {
+	var options []huh.Option[int]
+	for i, s := range statuses {
+		if !s.Wip.Local {
+			continue
+		}
+		label := fmt.Sprintf("#%s - %d unmerged, %s vs base", s.Issue, s.Unmerged, s.VsBase)
+		options = append(options, huh.NewOption(label, i))
+	}
+	if len(options) == 0 {
+		return 0, fmt.Errorf("no tracked issue has a local wip branch")
+	}
+	return ui.ShowForm(options, "Select an issue to check out")
+}
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the state of every tracked issue",
+	Long: `Lists each issue in tracking with its wip and pr branches, how far the wip branch is
+ahead of and behind its base and its pr branch, how the local pr branch compares to the
+remote one, the commits not yet merged into the pr branch, the last update time and the
+pull request state.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		asJSON, _ := cmd.Flags().GetBool("json")
+		checkout, _ := cmd.Flags().GetBool("checkout")
+		noPR, _ := cmd.Flags().GetBool("no-pr")
+		if asJSON && checkout {
+			return fmt.Errorf("--json and --checkout cannot be used together")
+		}
+
+		trackingData, err := tracking.Load()
+		if err != nil {
+			return fmt.Errorf("error loading tracking data: %w", err)
+		}
+
+		issues := make([]string, 0, len(trackingData.Issues))
+		for issue := range trackingData.Issues {
+			issues = append(issues, issue)
+		}
+		sort.Strings(issues)
+
+		statuses := make([]issueStatus, len(issues))
+		for i, issue := range issues {
+			statuses[i] = collectIssueStatus(trackingData, issue, !noPR)
+		}
+
+		if asJSON {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(statuses)
+		}
+
+		if len(statuses) == 0 {
+			fmt.Println("No tracked issues. Start one with 'mob init'")
+			return nil
+		}
+
+		printStatusTable(statuses)
+
+		if checkout {
+			index, err := selectIssueStatus(statuses)
+			if err != nil {
+				return err
+			}
+			wipBranch := fmt.Sprintf("wip/%s", statuses[index].Issue)
+			if err := repo.Checkout(wipBranch); err != nil {
+				return fmt.Errorf("error checking out '%s': %w", wipBranch, err)
+			}
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+	statusCmd.Flags().Bool("json", false, "Print the status as JSON")
+	statusCmd.Flags().BoolP("checkout", "c", false, "Select an issue and check out its wip branch")
+	statusCmd.Flags().Bool("no-pr", false, "Don't query GitHub for pull request state")
+}
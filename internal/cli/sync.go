@@ -0,0 +1,340 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/joaosaffran/mob/internal/git"
+	"github.com/joaosaffran/mob/internal/journal"
+	"github.com/joaosaffran/mob/internal/tracking"
+	"github.com/spf13/cobra"
+)
+
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Rebase the wip branch onto the latest base branch",
+	Long: `Fetch the remote, rebase wip/<issue> onto the current base branch and move its fork point.
+If pr/<issue> exists it is rebuilt on top of the new fork point and force-pushed. Each of its
+commits is replayed, including ones others pushed to it, except in squash-all mode where it
+stays a single commit. A pr branch with merge commits has to be rebased by hand.`,
+	RunE: runSync,
+}
+
+// runSync rebases the wip branch onto its base branch and rebuilds the pr branch on top
+func runSync(cmd *cobra.Command, args []string) error {
+	// Get current branch
+	currentBranch, err := repo.CurrentBranch()
+	if err != nil {
+		return fmt.Errorf("error getting current branch: %w", err)
+	}
+
+	// Verify we're on a wip branch
+	if !strings.HasPrefix(currentBranch, "wip/") {
+		return fmt.Errorf("not on a wip branch. Please checkout a wip/<issue> branch first")
+	}
+
+	// Extract issue from branch name
+	issue := strings.TrimPrefix(currentBranch, "wip/")
+	wipBranch := currentBranch
+	prBranch := fmt.Sprintf("pr/%s", issue)
+
+	// The rebase needs a clean worktree, and rolling it back resets the wip branch
+	dirty, err := repo.HasUncommittedChanges()
+	if err != nil {
+		return fmt.Errorf("error checking for uncommitted changes: %w", err)
+	}
+	if dirty {
+		return fmt.Errorf("'%s' has uncommitted changes; commit or stash them before syncing", wipBranch)
+	}
+
+	// Load tracking data
+	trackingData, err := tracking.Load()
+	if err != nil {
+		return fmt.Errorf("error loading tracking data: %w", err)
+	}
+
+	// Get fork point from tracking
+	forkPoint := trackingData.GetForkPoint(issue)
+	if forkPoint == "" {
+		return fmt.Errorf("no fork point found for this issue. Was this branch created with 'mob init'?")
+	}
+
+	baseBranch, _ := cmd.Flags().GetString("base")
+	if baseBranch == "" {
+		baseBranch = trackingData.GetBaseBranch(issue)
+	}
+	if baseBranch == "" {
+		return fmt.Errorf("no base branch recorded for this issue. Use --base to specify one")
+	}
+	baseBranch = strings.TrimPrefix(baseBranch, "origin/")
+
+	if err := repo.Fetch("origin"); err != nil {
+		return fmt.Errorf("error fetching from remote: %w", err)
+	}
+
+	// Prefer the remote base so we don't depend on the local copy being pulled
+	baseRef := baseBranch
+	if repo.BranchExists("origin/" + baseBranch) {
+		baseRef = "origin/" + baseBranch
+	}
+
+	newForkPoint, err := repo.GetCommitHash(baseRef)
+	if err != nil {
+		return fmt.Errorf("error getting commit for '%s': %w", baseRef, err)
+	}
+
+	if newForkPoint == forkPoint {
+		fmt.Printf("Already up to date with '%s'\n", baseRef)
+		return nil
+	}
+
+	// Commits that haven't reached the pr branch yet are the newest ones
+	oldCommits, err := repo.GetCommitsBetween(forkPoint, wipBranch)
+	if err != nil {
+		return fmt.Errorf("error getting commits: %w", err)
+	}
+	oldUnmerged := trackingData.GetUnmergedCommits(issue, oldCommits, repo.PatchIDs)
+
+	settings, err := loadCommitSettings()
+	if err != nil {
+		return err
+	}
+
+	// Track state for rollback
+	wipOriginalCommit, err := repo.GetCommitHash(wipBranch)
+	if err != nil {
+		return fmt.Errorf("error getting commit hash: %w", err)
+	}
+	prBranchExisted := repo.BranchExists(prBranch)
+	var prBranchOriginalCommit string
+	if prBranchExisted {
+		prBranchOriginalCommit, err = repo.GetCommitHash(prBranch)
+		if err != nil {
+			return fmt.Errorf("error getting commit hash: %w", err)
+		}
+	}
+
+	// Journal each step so an interrupted sync can be recovered
+	j, err := journal.Begin("sync", issue, commandArgs(cmd, args))
+	if err != nil {
+		return err
+	}
+	for _, branch := range []string{wipBranch, prBranch} {
+		if err := j.Record(branch); err != nil {
+			j.Finish()
+			return fmt.Errorf("error writing journal: %w", err)
+		}
+	}
+
+	// Rollback function to restore state on failure
+	rollback := func(errMsg string) error {
+		fmt.Println("Rolling back changes...")
+		keepRunning()
+
+		// Abort any in-progress rebase, which puts the wip branch back by itself
+		if repo.OperationInProgress("rebase-merge") || repo.OperationInProgress("rebase-apply") {
+			repo.AbortRebase()
+		}
+
+		// Reset the wip branch only if the rebase finished and moved it, so the worktree
+		// being reset is the one the rebase wrote
+		if current, err := repo.GetCommitHash(wipBranch); err == nil && current != wipOriginalCommit {
+			repo.Checkout(wipBranch)
+			repo.ResetHard(wipOriginalCommit)
+		}
+
+		// Restore pr branch to original state if it existed
+		if prBranchExisted {
+			repo.UpdateRef("refs/heads/"+prBranch, prBranchOriginalCommit, "")
+		}
+
+		// Restore the issue's tracking and close the journal
+		j.RestoreTracking()
+		j.Finish()
+
+		return fmt.Errorf("%s (changes rolled back)", errMsg)
+	}
+
+	// Rebase the wip commits onto the new base
+	if err := j.SetStep("rebase"); err != nil {
+		return rollback(fmt.Sprintf("error writing journal: %v", err))
+	}
+	if err := repo.RebaseOnto(newForkPoint, forkPoint, wipBranch); err != nil {
+		if errors.Is(err, git.ErrMergeConflict) {
+			return rollback(fmt.Sprintf("the wip commits conflict with '%s'; rebase '%s' by hand and run sync again", baseRef, wipBranch))
+		}
+		return rollback(fmt.Sprintf("error rebasing onto '%s': %v", baseRef, err))
+	}
+
+	newCommits, err := repo.GetCommitsBetween(newForkPoint, wipBranch)
+	if err != nil {
+		return rollback(fmt.Sprintf("error getting commits: %v", err))
+	}
+
+	// Record the new fork point, and what the rebuilt pr branch contains if there is one
+	saveTracking := func(merged []string, newTip string) error {
+		return tracking.Update(func(trackingData *tracking.TrackingData) error {
+			trackingData.SetForkPoint(issue, newForkPoint)
+			trackingData.SetBaseBranch(issue, baseBranch)
+			if !prBranchExisted {
+				return nil
+			}
+			trackingData.SetPRTip(issue, newTip)
+			if len(merged) == 0 {
+				return trackingData.SetMergedCommits(issue, "", nil, repo.PatchIDs)
+			}
+			return trackingData.SetMergedCommits(issue, merged[0], merged, repo.PatchIDs)
+		})
+	}
+
+	if prBranchExisted {
+		// Rebuild the pr branch from the rebased copies of the commits it already contained
+		merged, err := mergedAfterRebase(oldCommits, oldUnmerged, newCommits, repo.PatchIDs)
+		if err != nil {
+			return rollback(err.Error())
+		}
+
+		mode := trackingData.GetMode(issue)
+		message, _ := cmd.Flags().GetString("message")
+		if message == "" && mode == tracking.ModeSquashAll {
+			message, err = repo.GetCommitMessage(prBranch)
+			if err != nil {
+				return rollback(fmt.Sprintf("error getting pr commit message: %v", err))
+			}
+		}
+
+		if err := j.SetStep("rebuild-pr"); err != nil {
+			return rollback(fmt.Sprintf("error writing journal: %v", err))
+		}
+		var newTip string
+		if mode == tracking.ModeSquashAll {
+			// The pr branch is a single commit in this mode
+			newTip = newForkPoint
+			if len(merged) > 0 {
+				newTip, err = buildTreeCommit(settings, newForkPoint, merged[0], message)
+			}
+		} else {
+			newTip, err = rebuildPRCommits(settings, forkPoint, prBranchOriginalCommit, newForkPoint)
+		}
+		if err != nil {
+			return rollback(err.Error())
+		}
+
+		// Move the pr branch without checking it out
+		if err := repo.UpdateRef("refs/heads/"+prBranch, newTip, prBranchOriginalCommit); err != nil {
+			return rollback(fmt.Sprintf("error updating pr branch: %v", err))
+		}
+
+		if err := j.SetStep("save-tracking"); err != nil {
+			return rollback(fmt.Sprintf("error writing journal: %v", err))
+		}
+		if err := saveTracking(merged, newTip); err != nil {
+			return rollback(fmt.Sprintf("error saving tracking data: %v", err))
+		}
+
+		// History was rewritten, so the remote needs a force push
+		if err := j.SetPush("origin", prBranch, true); err != nil {
+			return rollback(fmt.Sprintf("error writing journal: %v", err))
+		}
+		if err := repo.PushForceWithLease("origin", prBranch); err != nil {
+			return rollback(pushErrorMessage(prBranch, err))
+		}
+	} else {
+		if err := j.SetStep("save-tracking"); err != nil {
+			return rollback(fmt.Sprintf("error writing journal: %v", err))
+		}
+		if err := saveTracking(nil, ""); err != nil {
+			return rollback(fmt.Sprintf("error saving tracking data: %v", err))
+		}
+	}
+
+	if err := j.Finish(); err != nil {
+		return fmt.Errorf("error removing journal: %w", err)
+	}
+
+	// Share tracking data when it's kept in a git ref; the branches are already pushed
+	if err := tracking.Push(); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+
+	fmt.Printf("Rebased '%s' onto '%s' (%s)\n", wipBranch, baseRef, shortHash(newForkPoint))
+	return nil
+}
+
+// rebuildPRCommits replays the pr branch's commits since the old fork point onto the new
+// one, one for one, so every update commit and any commit others pushed to the pr branch
+// is kept. Merge commits can't be replayed that way, so they stop the sync.
+func rebuildPRCommits(settings commitSettings, forkPoint, prTip, newForkPoint string) (string, error) {
+	commits, err := repo.GetCommitsBetween(forkPoint, prTip)
+	if err != nil {
+		return "", fmt.Errorf("error getting pr commits: %w", err)
+	}
+	for _, c := range commits {
+		parents, err := repo.GetCommitParents(c)
+		if err != nil {
+			return "", fmt.Errorf("error getting commit parents: %w", err)
+		}
+		if len(parents) > 1 {
+			return "", fmt.Errorf("the pr branch has merge commit %s, which sync can't replay; rebase the pr branch onto the new base by hand", shortHash(c))
+		}
+	}
+	return buildPreservedCommits(settings, newForkPoint, reversed(commits), "")
+}
+
+// mergedAfterRebase finds the rebased copies of the commits that had reached the pr branch,
+// newest first, by matching patch IDs. Merged commits the rebase dropped, such as ones the
+// base branch already has, have no copy. It fails when a rebased commit matches none of the
+// old ones, or when the merged copies aren't the oldest commits, since the pr branch is
+// rebuilt from the history up to the newest of them.
+func mergedAfterRebase(oldCommits, oldUnmerged, newCommits []string, patchIDs tracking.PatchIDFunc) ([]string, error) {
+	oldIDs, err := patchIDs(oldCommits)
+	if err != nil {
+		return nil, fmt.Errorf("error computing patch IDs: %w", err)
+	}
+	newIDs, err := patchIDs(newCommits)
+	if err != nil {
+		return nil, fmt.Errorf("error computing patch IDs: %w", err)
+	}
+
+	unmergedSet := make(map[string]bool)
+	for _, c := range oldUnmerged {
+		unmergedSet[c] = true
+	}
+	// How many old commits carried each patch, for either side
+	merged := make(map[string]int)
+	unmerged := make(map[string]int)
+	for _, c := range oldCommits {
+		if id := oldIDs[c]; id != "" && unmergedSet[c] {
+			unmerged[id]++
+		} else if id != "" {
+			merged[id]++
+		}
+	}
+
+	// Match oldest first; merged commits are older than unmerged ones with the same patch
+	count := 0
+	for i := len(newCommits) - 1; i >= 0; i-- {
+		c := newCommits[i]
+		id := newIDs[c]
+		switch {
+		case id != "" && merged[id] > 0:
+			merged[id]--
+			if count != len(newCommits)-1-i {
+				return nil, fmt.Errorf("rebased commit %s was already in the pr branch but follows commits that weren't", shortHash(c))
+			}
+			count++
+		case id != "" && unmerged[id] > 0:
+			unmerged[id]--
+		default:
+			return nil, fmt.Errorf("can't tell whether rebased commit %s was already in the pr branch", shortHash(c))
+		}
+	}
+	return newCommits[len(newCommits)-count:], nil
+}
+
+func init() {
+	rootCmd.AddCommand(syncCmd)
+	syncCmd.Flags().StringP("base", "b", "", "Base branch to rebase onto (defaults to the issue's base branch)")
+	syncCmd.Flags().StringP("message", "m", "", "Commit message for the rebuilt pr branch in squash-all mode (defaults to its latest message)")
+}
@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/joaosaffran/mob/internal/tracking"
+)
+
+// fixedPatchIDs returns a PatchIDFunc that looks commits up in table. Commits missing
+// from it have no changes, like empty commits.
+func fixedPatchIDs(table map[string]string) tracking.PatchIDFunc {
+	return func(commits []string) (map[string]string, error) {
+		ids := make(map[string]string)
+		for _, c := range commits {
+			if id, ok := table[c]; ok {
+				ids[c] = id
+			}
+		}
+		return ids, nil
+	}
+}
+
+func TestMergedAfterRebase(t *testing.T) {
+	tests := []struct {
+		name        string
+		oldCommits  []string
+		oldUnmerged []string
+		newCommits  []string
+		ids         map[string]string
+		want        []string
+		wantErr     string
+	}{
+		{
+			name:        "every commit rebased",
+			oldCommits:  []string{"c3", "c2", "c1"},
+			oldUnmerged: []string{"c3"},
+			newCommits:  []string{"n3", "n2", "n1"},
+			ids:         map[string]string{"c1": "p1", "c2": "p2", "c3": "p3", "n1": "p1", "n2": "p2", "n3": "p3"},
+			want:        []string{"n2", "n1"},
+		},
+		{
+			name:        "base already had a merged commit",
+			oldCommits:  []string{"c3", "c2", "c1"},
+			oldUnmerged: []string{"c3"},
+			newCommits:  []string{"n3", "n2"},
+			ids:         map[string]string{"c1": "p1", "c2": "p2", "c3": "p3", "n2": "p2", "n3": "p3"},
+			want:        []string{"n2"},
+		},
+		{
+			name:        "base already had an unmerged commit",
+			oldCommits:  []string{"c3", "c2", "c1"},
+			oldUnmerged: []string{"c3"},
+			newCommits:  []string{"n2", "n1"},
+			ids:         map[string]string{"c1": "p1", "c2": "p2", "c3": "p3", "n1": "p1", "n2": "p2"},
+			want:        []string{"n2", "n1"},
+		},
+		{
+			name:        "nothing merged yet",
+			oldCommits:  []string{"c2", "c1"},
+			oldUnmerged: []string{"c2", "c1"},
+			newCommits:  []string{"n2", "n1"},
+			ids:         map[string]string{"c1": "p1", "c2": "p2", "n1": "p1", "n2": "p2"},
+			want:        []string{},
+		},
+		{
+			name:        "a change made again after a revert",
+			oldCommits:  []string{"c3", "c2", "c1"},
+			oldUnmerged: []string{"c3", "c2"},
+			newCommits:  []string{"n3", "n2", "n1"},
+			ids:         map[string]string{"c1": "p1", "c2": "revert", "c3": "p1", "n1": "p1", "n2": "revert", "n3": "p1"},
+			want:        []string{"n1"},
+		},
+		{
+			name:        "rebased commit changed by conflict resolution",
+			oldCommits:  []string{"c2", "c1"},
+			oldUnmerged: []string{"c2"},
+			newCommits:  []string{"n2", "n1"},
+			ids:         map[string]string{"c1": "p1", "c2": "p2", "n1": "resolved", "n2": "p2"},
+			wantErr:     "can't tell whether rebased commit n1",
+		},
+		{
+			name:        "rebased commit without changes",
+			oldCommits:  []string{"c2", "c1"},
+			oldUnmerged: []string{"c2"},
+			newCommits:  []string{"n2", "n1"},
+			ids:         map[string]string{"c1": "p1", "c2": "p2", "n2": "p2"},
+			wantErr:     "can't tell whether rebased commit n1",
+		},
+		{
+			name:        "merged commit after an unmerged one",
+			oldCommits:  []string{"c2", "c1"},
+			oldUnmerged: []string{"c1"},
+			newCommits:  []string{"n2", "n1"},
+			ids:         map[string]string{"c1": "p1", "c2": "p2", "n1": "p1", "n2": "p2"},
+			wantErr:     "follows commits that weren't",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mergedAfterRebase(tt.oldCommits, tt.oldUnmerged, tt.newCommits, fixedPatchIDs(tt.ids))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("mergedAfterRebase() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("mergedAfterRebase() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergedAfterRebasePatchIDError(t *testing.T) {
+	failing := func([]string) (map[string]string, error) {
+		return nil, errors.New("patch-id failed")
+	}
+	if _, err := mergedAfterRebase([]string{"c1"}, nil, []string{"n1"}, failing); err == nil {
+		t.Error("mergedAfterRebase() succeeded without patch IDs")
+	}
+}
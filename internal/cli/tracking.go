@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/joaosaffran/mob/internal/config"
+	"github.com/joaosaffran/mob/internal/tracking"
+	"github.com/spf13/cobra"
+)
+
+// requireSharedTracking fails unless tracking data is kept in a git ref
+func requireSharedTracking() error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("error loading config: %w", err)
+	}
+	if cfg.Tracking.Backend == config.BackendFile {
+		return fmt.Errorf("tracking data is stored in a file; set 'tracking.backend' to '%s' or '%s' in .mob/config.yaml to share it", config.BackendGitRef, config.BackendGitNotes)
+	}
+	return nil
+}
+
+var trackingCmd = &cobra.Command{
+	Use:   "tracking",
+	Short: "Manage the tracking data",
+}
+
+var trackingPushCmd = &cobra.Command{
+	Use:   "push",
+	Short: "Push the tracking ref to the remote",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := requireSharedTracking(); err != nil {
+			return err
+		}
+		if err := tracking.Push(); err != nil {
+			return err
+		}
+		fmt.Println("Tracking data pushed")
+		return nil
+	},
+}
+
+var trackingPullCmd = &cobra.Command{
+	Use:   "pull",
+	Short: "Fetch the tracking ref from the remote and merge it",
+	Long: `Fetches the tracking ref and merges it into the local one. Issues tracked on both
+sides keep the most recently updated entry.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := requireSharedTracking(); err != nil {
+			return err
+		}
+		if err := tracking.Pull(); err != nil {
+			return err
+		}
+		fmt.Println("Tracking data is up to date")
+		return nil
+	},
+}
+
+var trackingMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Upgrade the tracking data to the current schema",
+	Long: `Upgrades the tracking data to the schema version of this mob, keeping a backup of
+the old data in .mob/. Every command does this automatically when it loads the data.
+
+With --check nothing is changed; the command fails if the data needs a migration or
+has problems, so it can run in CI.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		check, _ := cmd.Flags().GetBool("check")
+
+		if check {
+			result, err := tracking.Check()
+			if err != nil {
+				return fmt.Errorf("error reading tracking data: %w", err)
+			}
+			for _, problem := range result.Problems {
+				fmt.Printf("  %s\n", problem)
+			}
+			switch {
+			case len(result.Problems) > 0:
+				return fmt.Errorf("tracking data (schema version %d) has %d problem(s)", result.Version, len(result.Problems))
+			case result.NeedsMigration:
+				return fmt.Errorf("tracking data has schema version %d and needs a migration to %d; run 'mob tracking migrate'", result.Version, tracking.SchemaVersion)
+			}
+			fmt.Printf("Tracking data is valid (schema version %d)\n", result.Version)
+			return nil
+		}
+
+		version, err := tracking.Migrate()
+		if err != nil {
+			return fmt.Errorf("error migrating tracking data: %w", err)
+		}
+		if version == tracking.SchemaVersion {
+			fmt.Printf("Tracking data is already at schema version %d\n", version)
+			return nil
+		}
+		fmt.Printf("Migrated tracking data from schema version %d to %d (backup in .mob/tracking.v%d.json.bak)\n", version, tracking.SchemaVersion, version)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(trackingCmd)
+	trackingCmd.AddCommand(trackingPushCmd)
+	trackingCmd.AddCommand(trackingPullCmd)
+	trackingCmd.AddCommand(trackingMigrateCmd)
+	trackingMigrateCmd.Flags().Bool("check", false, "Only validate the tracking data; fail if it needs a migration")
+}
@@ -1,149 +1,394 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/joaosaffran/mob/internal/git"
+	"github.com/joaosaffran/mob/internal/journal"
 	"github.com/joaosaffran/mob/internal/tracking"
 	"github.com/spf13/cobra"
 )
 
+// pushErrorMessage explains a failed push of the pr branch
+func pushErrorMessage(prBranch string, err error) string {
+	if errors.Is(err, git.ErrNonFastForward) {
+		return fmt.Sprintf("'origin/%s' has commits that aren't in '%s'; fetch and look at them before updating again", prBranch, prBranch)
+	}
+	return fmt.Sprintf("error pushing to remote: %v", err)
+}
+
 var updateCmd = &cobra.Command{
 	Use:   "update",
 	Short: "Squash wip commits and merge into pr branch",
 	Long: `Squash all new commits from wip/<issue> and merge them into pr/<issue>.
-Only commits that haven't been merged yet will be included.`,
-	RunE: func(cmd *cobra.Command, args []string) error {
-		// Get current branch
-		currentBranch, err := git.CurrentBranch()
+Only commits that haven't been merged yet will be included.
+
+The history mode controls the shape of pr/<issue>:
+  per-update  add one squash commit per update (default)
+  squash-all  rewrite pr/<issue> to a single commit and force-push with lease
+  preserve    cherry-pick every new wip commit
+
+Without -m the commit message is written in the git editor, starting from
+.mob/commit_template. --amend-message rewords the newest pr commit and force-pushes it.`,
+	RunE: runUpdate,
+}
+
+// runUpdate carries the new wip commits onto the pr branch and pushes it
+func runUpdate(cmd *cobra.Command, args []string) error {
+	strategy, _ := cmd.Flags().GetString("strategy")
+	if err := validateStrategy(strategy); err != nil {
+		return err
+	}
+	message, _ := cmd.Flags().GetString("message")
+	mode, _ := cmd.Flags().GetString("mode")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	asJSON, _ := cmd.Flags().GetBool("json")
+	if asJSON && !dryRun {
+		return fmt.Errorf("--json can only be used with --dry-run")
+	}
+	amend, _ := cmd.Flags().GetBool("amend-message")
+	if amend && (dryRun || mode != "" || strategy != "") {
+		return fmt.Errorf("--amend-message can't be combined with --dry-run, --mode or --strategy")
+	}
+
+	// Get current branch
+	currentBranch, err := repo.CurrentBranch()
+	if err != nil {
+		return fmt.Errorf("error getting current branch: %w", err)
+	}
+
+	// Verify we're on a wip branch
+	if !strings.HasPrefix(currentBranch, "wip/") {
+		return fmt.Errorf("not on a wip branch. Please checkout a wip/<issue> branch first")
+	}
+
+	// Extract issue from branch name
+	issue := strings.TrimPrefix(currentBranch, "wip/")
+	wipBranch := currentBranch
+	prBranch := fmt.Sprintf("pr/%s", issue)
+
+	// Load tracking data
+	trackingData, err := tracking.Load()
+	if err != nil {
+		return fmt.Errorf("error loading tracking data: %w", err)
+	}
+
+	// Get fork point from tracking
+	forkPoint := trackingData.GetForkPoint(issue)
+	if forkPoint == "" {
+		return fmt.Errorf("no fork point found for this issue. Was this branch created with 'mob init'?")
+	}
+
+	if amend {
+		return amendPRMessage(cmd, args, issue, forkPoint, message)
+	}
+
+	// Use the issue's recorded history mode unless one was given
+	if mode == "" {
+		mode = trackingData.GetMode(issue)
+	}
+	if !tracking.IsValidMode(mode) {
+		return fmt.Errorf("invalid mode '%s' (expected one of %v)", mode, tracking.Modes)
+	}
+
+	// Get all commits in wip branch since fork point
+	allCommits, err := repo.GetCommitsBetween(forkPoint, wipBranch)
+	if err != nil {
+		return fmt.Errorf("error getting commits: %w", err)
+	}
+
+	// Filter out already merged commits
+	unmergedCommits := trackingData.GetUnmergedCommits(issue, allCommits, repo.PatchIDs)
+
+	// Describe what would happen without touching anything
+	if dryRun {
+		plan, err := buildUpdatePlan(issue, mode, forkPoint, carriedCommits(mode, allCommits, unmergedCommits))
 		if err != nil {
-			return fmt.Errorf("error getting current branch: %w", err)
+			return err
 		}
-
-		// Verify we're on a wip branch
-		if !strings.HasPrefix(currentBranch, "wip/") {
-			return fmt.Errorf("not on a wip branch. Please checkout a wip/<issue> branch first")
+		if asJSON {
+			return plan.printJSON()
 		}
+		plan.print()
+		return nil
+	}
+
+	if len(allCommits) == 0 {
+		fmt.Println("No commits to merge")
+		return nil
+	}
+
+	if len(unmergedCommits) == 0 {
+		fmt.Println("No new commits to merge")
+		return nil
+	}
+
+	fmt.Printf("Found %d new commit(s) to merge\n", len(unmergedCommits))
 
-		// Extract issue from branch name
-		issue := strings.TrimPrefix(currentBranch, "wip/")
-		wipBranch := currentBranch
-		prBranch := fmt.Sprintf("pr/%s", issue)
+	settings, err := loadCommitSettings()
+	if err != nil {
+		return err
+	}
 
-		// Load tracking data
-		trackingData, err := tracking.Load()
+	// Track state for rollback
+	prBranchExisted := repo.BranchExists(prBranch)
+	var prBranchOriginalCommit string
+	if prBranchExisted {
+		prBranchOriginalCommit, err = repo.GetCommitHash(prBranch)
 		if err != nil {
-			return fmt.Errorf("error loading tracking data: %w", err)
+			return fmt.Errorf("error getting commit hash: %w", err)
 		}
+	}
 
-		// Get fork point from tracking
-		forkPoint := trackingData.GetForkPoint(issue)
-		if forkPoint == "" {
-			return fmt.Errorf("no fork point found for this issue. Was this branch created with 'mob init'?")
-		}
+	// New commits go on top of the pr branch, or the fork point for a new one
+	parent := forkPoint
+	if prBranchExisted {
+		parent = prBranchOriginalCommit
+	}
 
-		// Get all commits in wip branch since fork point
-		allCommits, err := git.GetCommitsBetween(forkPoint, wipBranch)
+	// Without -m, write the message in the editor, starting from .mob/commit_template
+	journalArgs := commandArgs(cmd, args)
+	if message == "" && mode != tracking.ModePreserve {
+		base := parent
+		if mode == tracking.ModeSquashAll {
+			base = forkPoint
+		}
+		data, err := commitMessageDataFor(issue, carriedCommits(mode, allCommits, unmergedCommits), base, allCommits[0])
 		if err != nil {
-			return fmt.Errorf("error getting commits: %w", err)
+			return err
 		}
-
-		if len(allCommits) == 0 {
-			fmt.Println("No commits to merge")
-			return nil
+		initial, err := renderCommitMessage(data)
+		if err != nil {
+			return err
 		}
+		help := fmt.Sprintf("Enter the message of the commit for '%s'. Lines starting\nwith '#' are ignored, and an empty message aborts the update.", prBranch)
+		if message, err = editMessage(initial, help); err != nil {
+			return err
+		}
+		// Recovering reruns the update, which shouldn't ask again
+		journalArgs = append(journalArgs, "--message="+message)
+	}
 
-		// Filter out already merged commits
-		unmergedCommits := trackingData.GetUnmergedCommits(issue, allCommits)
-
-		if len(unmergedCommits) == 0 {
-			fmt.Println("No new commits to merge")
-			return nil
+	// Journal each step so an interrupted update can be recovered
+	j, err := journal.Begin("update", issue, journalArgs)
+	if err != nil {
+		return err
+	}
+	for _, branch := range []string{wipBranch, prBranch} {
+		if err := j.Record(branch); err != nil {
+			j.Finish()
+			return fmt.Errorf("error writing journal: %w", err)
 		}
+	}
 
-		fmt.Printf("Found %d new commit(s) to merge\n", len(unmergedCommits))
+	// Rollback function to restore state on failure
+	rollback := func(errMsg string) error {
+		fmt.Println("Rolling back changes...")
+		keepRunning()
 
-		// Track state for rollback
-		prBranchExisted := git.BranchExists(prBranch)
-		var prBranchOriginalCommit string
+		// Restore pr branch to original state, or remove it if this update created it
 		if prBranchExisted {
-			prBranchOriginalCommit, _ = git.GetCommitHash(prBranch)
+			repo.UpdateRef("refs/heads/"+prBranch, prBranchOriginalCommit, "")
+		} else {
+			repo.DeleteRef("refs/heads/" + prBranch)
 		}
 
-		// Rollback function to restore state on failure
-		rollback := func(errMsg string) error {
-			fmt.Println("Rolling back changes...")
+		// Restore the issue's tracking and close the journal
+		j.RestoreTracking()
+		j.Finish()
 
-			// Abort any in-progress merge
-			git.AbortMerge()
+		return fmt.Errorf("%s (changes rolled back)", errMsg)
+	}
 
-			// Go back to wip branch
-			git.Checkout(wipBranch)
+	// Build the new pr branch tip from objects only, so the checkout never changes
+	if err := j.SetStep("build-commits"); err != nil {
+		return rollback(fmt.Sprintf("error writing journal: %v", err))
+	}
+	var newTip string
+	switch mode {
+	case tracking.ModePreserve:
+		newTip, err = buildPreservedCommits(settings, parent, reversed(unmergedCommits), strategy)
+	case tracking.ModeSquashAll:
+		// Start over from the fork point so the pr branch holds a single commit
+		newTip, err = buildTreeCommit(settings, forkPoint, wipBranch, message)
+	default:
+		newTip, err = buildSquashCommit(settings, parent, reversed(unmergedCommits), strategy, message)
+	}
+	if err != nil {
+		return rollback(err.Error())
+	}
 
-			// Restore pr branch to original state if it existed
-			if prBranchExisted && prBranchOriginalCommit != "" {
-				git.Checkout(prBranch)
-				git.ResetHard(prBranchOriginalCommit)
-				git.Checkout(wipBranch)
-			}
+	// Move the pr branch, failing if it changed since we read it
+	if err := j.SetStep("update-ref"); err != nil {
+		return rollback(fmt.Sprintf("error writing journal: %v", err))
+	}
+	oldValue := git.ZeroHash
+	if prBranchExisted {
+		oldValue = prBranchOriginalCommit
+	}
+	if err := repo.UpdateRef("refs/heads/"+prBranch, newTip, oldValue); err != nil {
+		return rollback(fmt.Sprintf("error updating pr branch: %v", err))
+	}
 
-			return fmt.Errorf("%s (changes rolled back)", errMsg)
+	// Update tracking data
+	if err := j.SetStep("save-tracking"); err != nil {
+		return rollback(fmt.Sprintf("error writing journal: %v", err))
+	}
+	latestCommit := allCommits[0] // Most recent commit
+	err = tracking.Update(func(trackingData *tracking.TrackingData) error {
+		trackingData.SetMode(issue, mode)
+		trackingData.SetPRTip(issue, newTip)
+		trackingData.AddEvent(issue, tracking.Event{Type: tracking.EventUpdate, At: time.Now()})
+		if mode == tracking.ModeSquashAll {
+			return trackingData.SetMergedCommits(issue, latestCommit, allCommits, repo.PatchIDs)
 		}
+		return trackingData.UpdateIssueTracking(issue, latestCommit, unmergedCommits, repo.PatchIDs)
+	})
+	if err != nil {
+		return rollback(fmt.Sprintf("error saving tracking data: %v", err))
+	}
 
-		// Create or checkout pr branch
-		if prBranchExisted {
-			if err := git.Checkout(prBranch); err != nil {
-				return rollback(fmt.Sprintf("error checking out pr branch: %v", err))
-			}
-		} else {
-			// Create pr branch from fork point
-			if err := git.Run("checkout", forkPoint); err != nil {
-				return rollback(fmt.Sprintf("error checking out fork point: %v", err))
-			}
-			if err := git.CheckoutNewBranch(prBranch); err != nil {
-				return rollback(fmt.Sprintf("error creating pr branch: %v", err))
-			}
-		}
+	// Push to remote, rewriting it when the pr branch was rebuilt
+	if err := j.SetPush("origin", prBranch, mode == tracking.ModeSquashAll); err != nil {
+		return rollback(fmt.Sprintf("error writing journal: %v", err))
+	}
+	if mode == tracking.ModeSquashAll {
+		err = repo.PushForceWithLease("origin", prBranch)
+	} else {
+		err = repo.PushSetUpstream("origin", prBranch)
+	}
+	if err != nil {
+		return rollback(pushErrorMessage(prBranch, err))
+	}
 
-		// Merge squash from wip branch
-		if err := git.MergeSquash(wipBranch); err != nil {
-			return rollback(fmt.Sprintf("error merging wip branch: %v", err))
-		}
+	if err := j.Finish(); err != nil {
+		return fmt.Errorf("error removing journal: %w", err)
+	}
+	warnUnpushedLFS(prBranch, parent, newTip)
 
-		// Get commit message
-		message, _ := cmd.Flags().GetString("message")
+	// Share tracking data when it's kept in a git ref; the branches are already pushed
+	if err := tracking.Push(); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
 
-		// Create the squash commit
-		if err := git.CommitSquash(message); err != nil {
-			return rollback(fmt.Sprintf("error creating squash commit: %v", err))
-		}
+	fmt.Printf("Successfully merged %d commit(s) into '%s' and pushed to remote\n", len(unmergedCommits), prBranch)
+	return nil
+}
 
-		// Update tracking data
-		latestCommit := allCommits[0] // Most recent commit
-		trackingData.UpdateIssueTracking(issue, latestCommit, unmergedCommits)
-		if err := trackingData.Save(); err != nil {
-			return rollback(fmt.Sprintf("error saving tracking data: %v", err))
-		}
+// amendPRMessage rewords the newest commit on the pr branch, keeping its tree, parents and
+// author, and force-pushes the result. Without a message the current one is edited.
+func amendPRMessage(cmd *cobra.Command, args []string, issue, forkPoint, message string) error {
+	prBranch := fmt.Sprintf("pr/%s", issue)
+	if !repo.BranchExists(prBranch) {
+		return fmt.Errorf("branch '%s' does not exist. Run 'mob update' first", prBranch)
+	}
+	oldTip, err := repo.GetCommitHash(prBranch)
+	if err != nil {
+		return fmt.Errorf("error getting commit hash: %w", err)
+	}
+	if oldTip == forkPoint {
+		return fmt.Errorf("'%s' has no commits to reword", prBranch)
+	}
 
-		// Push to remote
-		if err := git.PushSetUpstream("origin", prBranch); err != nil {
-			return rollback(fmt.Sprintf("error pushing to remote: %v", err))
+	journalArgs := commandArgs(cmd, args)
+	if message == "" {
+		current, err := repo.GetCommitMessage(oldTip)
+		if err != nil {
+			return fmt.Errorf("error getting commit message: %w", err)
 		}
-
-		// Switch back to wip branch
-		if err := git.Checkout(wipBranch); err != nil {
-			// Don't rollback here, the commit was successful
-			return fmt.Errorf("error switching back to wip branch: %w (but merge was successful)", err)
+		help := fmt.Sprintf("Reword commit %s on '%s'. Lines starting with '#' are ignored,\nand an empty message aborts.", shortHash(oldTip), prBranch)
+		if message, err = editMessage(current, help); err != nil {
+			return err
 		}
+		journalArgs = append(journalArgs, "--message="+message)
+	}
+
+	settings, err := loadCommitSettings()
+	if err != nil {
+		return err
+	}
+	tree, err := repo.TreeOf(oldTip)
+	if err != nil {
+		return fmt.Errorf("error reading tree of '%s': %w", prBranch, err)
+	}
+	parents, err := repo.GetCommitParents(oldTip)
+	if err != nil {
+		return fmt.Errorf("error getting commit parents: %w", err)
+	}
+	author, err := repo.GetCommitAuthor(oldTip)
+	if err != nil {
+		return fmt.Errorf("error getting commit author: %w", err)
+	}
+
+	j, err := journal.Begin("update", issue, journalArgs)
+	if err != nil {
+		return err
+	}
+	if err := j.Record(prBranch); err != nil {
+		j.Finish()
+		return fmt.Errorf("error writing journal: %w", err)
+	}
 
-		fmt.Printf("Successfully merged %d commit(s) into '%s' and pushed to remote\n", len(unmergedCommits), prBranch)
+	rollback := func(errMsg string) error {
+		fmt.Println("Rolling back changes...")
+		keepRunning()
+		repo.UpdateRef("refs/heads/"+prBranch, oldTip, "")
+		j.RestoreTracking()
+		j.Finish()
+		return fmt.Errorf("%s (changes rolled back)", errMsg)
+	}
+
+	if err := j.SetStep("build-commits"); err != nil {
+		return rollback(fmt.Sprintf("error writing journal: %v", err))
+	}
+	newTip, err := settings.commitTree(tree, git.CommitOptions{Parents: parents, Message: message, Author: &author})
+	if err != nil {
+		return rollback(fmt.Sprintf("error rewording %s: %v", shortHash(oldTip), err))
+	}
+
+	if err := j.SetStep("update-ref"); err != nil {
+		return rollback(fmt.Sprintf("error writing journal: %v", err))
+	}
+	if err := repo.UpdateRef("refs/heads/"+prBranch, newTip, oldTip); err != nil {
+		return rollback(fmt.Sprintf("error updating pr branch: %v", err))
+	}
+
+	// Record the new tip so the pre-push hook knows mob built it
+	if err := j.SetStep("save-tracking"); err != nil {
+		return rollback(fmt.Sprintf("error writing journal: %v", err))
+	}
+	err = tracking.Update(func(trackingData *tracking.TrackingData) error {
+		trackingData.SetPRTip(issue, newTip)
 		return nil
-	},
+	})
+	if err != nil {
+		return rollback(fmt.Sprintf("error saving tracking data: %v", err))
+	}
+
+	// The old commit may already be on the remote, so the push rewrites it
+	if err := j.SetPush("origin", prBranch, true); err != nil {
+		return rollback(fmt.Sprintf("error writing journal: %v", err))
+	}
+	if err := repo.PushForceWithLease("origin", prBranch); err != nil {
+		return rollback(pushErrorMessage(prBranch, err))
+	}
+
+	if err := j.Finish(); err != nil {
+		return fmt.Errorf("error removing journal: %w", err)
+	}
+	fmt.Printf("Reworded %s on '%s' and pushed to remote\n", shortHash(oldTip), prBranch)
+	return nil
 }
 
 func init() {
 	rootCmd.AddCommand(updateCmd)
-	updateCmd.Flags().StringP("message", "m", "", "Commit message for the squash commit")
-	updateCmd.MarkFlagRequired("message")
+	updateCmd.Flags().StringP("message", "m", "", "Commit message for the squash commit (not used in preserve mode; opens the editor if not given)")
+	updateCmd.Flags().Bool("amend-message", false, "Reword the newest commit on the pr branch instead of carrying new commits")
+	updateCmd.Flags().Bool("dry-run", false, "Print what would happen without changing anything")
+	updateCmd.Flags().Bool("json", false, "Print the dry-run plan as JSON")
+	updateCmd.Flags().String("mode", "", "History mode: squash-all, per-update or preserve (defaults to the issue's mode)")
+	updateCmd.Flags().StringP("strategy", "X", "", "Resolve conflicts automatically with this merge option (theirs or ours)")
 }
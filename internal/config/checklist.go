@@ -23,11 +23,11 @@ type ChecklistItem struct {
 
 // getChecklistPath returns the path to the checklist file
 func getChecklistPath() (string, error) {
-	cwd, err := os.Getwd()
+	dir, err := getConfigDir()
 	if err != nil {
 		return "", err
 	}
-	return filepath.Join(cwd, configDir, checklistFile), nil
+	return filepath.Join(dir, checklistFile), nil
 }
 
 // LoadChecklist loads the checklist from the yaml file
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+)
+
+const commitTemplateFile = "commit_template"
+
+// DefaultCommitTemplate is the commit message offered by 'mob update' when the repository
+// has no .mob/commit_template. Lines starting with '#' are removed after editing.
+const DefaultCommitTemplate = `{{if .Title}}{{.Title}}{{else}}Update #{{.Issue}}{{end}}
+{{if .Subjects}}
+{{range .Subjects}}- {{.}}
+{{end}}{{end}}
+# Changes carried onto pr/{{.Issue}}:
+#
+{{comment .DiffStat}}
+`
+
+// LoadCommitTemplate returns the text/template source of the update commit message,
+// falling back to DefaultCommitTemplate
+func LoadCommitTemplate() (string, error) {
+	dir, err := getConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, commitTemplateFile))
+	if os.IsNotExist(err) {
+		return DefaultCommitTemplate, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/joaosaffran/mob/internal/git"
+	"gopkg.in/yaml.v3"
+)
+
+const configFile = "config.yaml"
+
+// Tracking backends
+const (
+	// BackendFile keeps tracking data in .mob/tracking.json
+	BackendFile = "file"
+	// BackendGitRef keeps tracking data in a git ref that can be pushed and fetched
+	BackendGitRef = "git-ref"
+	// BackendGitNotes keeps each issue's tracking data in a git note on its fork point
+	BackendGitNotes = "git-notes"
+)
+
+// Config represents the repository configuration
+type Config struct {
+	Tracking TrackingConfig `yaml:"tracking"`
+	Commit   CommitConfig   `yaml:"commit"`
+}
+
+// TrackingConfig selects where tracking data is stored
+type TrackingConfig struct {
+	Backend string `yaml:"backend"`
+	Ref     string `yaml:"ref"`
+	Remote  string `yaml:"remote"`
+}
+
+// CommitConfig controls the identity and signature of the commits mob writes to pr branches
+type CommitConfig struct {
+	// Sign overrides commit.gpgsign when set
+	Sign *bool `yaml:"sign"`
+	// SigningKey overrides user.signingkey
+	SigningKey string `yaml:"signing_key"`
+	// Format overrides gpg.format: "openpgp", "x509" or "ssh"
+	Format    string         `yaml:"format"`
+	Author    IdentityConfig `yaml:"author"`
+	Committer IdentityConfig `yaml:"committer"`
+}
+
+// IdentityConfig is a name and email; git's user.name and user.email fill in what is left empty
+type IdentityConfig struct {
+	Name  string `yaml:"name"`
+	Email string `yaml:"email"`
+}
+
+// getConfigDir returns the .mob directory at the root of the repository
+func getConfigDir() (string, error) {
+	root, err := git.Default().Root()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, configDir), nil
+}
+
+// LoadConfig loads the configuration, filling in defaults for missing values
+func LoadConfig() (*Config, error) {
+	dir, err := getConfigDir()
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg Config
+	data, err := os.ReadFile(filepath.Join(dir, configFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.Tracking.Backend == "" {
+		cfg.Tracking.Backend = BackendFile
+	}
+	if cfg.Tracking.Ref == "" {
+		cfg.Tracking.Ref = "refs/mob/tracking"
+		if cfg.Tracking.Backend == BackendGitNotes {
+			cfg.Tracking.Ref = "refs/notes/mob"
+		}
+	}
+	if cfg.Tracking.Remote == "" {
+		cfg.Tracking.Remote = "origin"
+	}
+	return &cfg, nil
+}
@@ -0,0 +1,446 @@
+// Package diff parses the output of git diff into files, hunks and lines.
+package diff
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DevNull is the path git diff uses for the missing side of an added or deleted file
+const DevNull = "/dev/null"
+
+// LineKind tells whether a line was kept, added or removed
+type LineKind int
+
+// Line kinds
+const (
+	Context LineKind = iota
+	Added
+	Removed
+)
+
+// Prefix returns the character that starts lines of this kind in a unified diff
+func (k LineKind) Prefix() string {
+	switch k {
+	case Added:
+		return "+"
+	case Removed:
+		return "-"
+	default:
+		return " "
+	}
+}
+
+// Line is one line of a hunk. OldLine and NewLine are its line numbers in the old and
+// new file, or 0 on the side it doesn't exist.
+type Line struct {
+	Kind    LineKind
+	Content string
+	OldLine int
+	NewLine int
+	// NoNewline is set when the line is the last of its file and has no newline at the end
+	NoNewline bool
+}
+
+// Hunk is a block of changed lines and the context around them
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	// Section is the function or heading git printed after the ranges
+	Section string
+	Lines   []Line
+}
+
+// Header returns the hunk's @@ line
+func (h *Hunk) Header() string {
+	header := fmt.Sprintf("@@ -%s +%s @@", hunkRange(h.OldStart, h.OldLines), hunkRange(h.NewStart, h.NewLines))
+	if h.Section != "" {
+		header += " " + h.Section
+	}
+	return header
+}
+
+func hunkRange(start, lines int) string {
+	if lines == 1 {
+		return strconv.Itoa(start)
+	}
+	return fmt.Sprintf("%d,%d", start, lines)
+}
+
+// Source says where a change lives when a diff combines commits with the working tree
+type Source string
+
+// Sources of a change. A diff of two commits leaves Source empty.
+const (
+	Committed Source = "committed"
+	Staged    Source = "staged"
+	Unstaged  Source = "unstaged"
+	Untracked Source = "untracked"
+)
+
+// File is the change to one file. OldPath is empty for new files and NewPath for
+// deleted ones; modes are empty when git didn't print them.
+type File struct {
+	OldPath string
+	NewPath string
+	OldMode string
+	NewMode string
+	OldHash string
+	NewHash string
+
+	IsNew     bool
+	IsDeleted bool
+	IsRename  bool
+	IsCopy    bool
+	// Similarity is the similarity index of a rename or copy, in percent
+	Similarity int
+	// IsBinary is set when git didn't print a text diff for the file
+	IsBinary bool
+	// Submodule and LFS are set for submodule and Git LFS pointer changes
+	Submodule *SubmoduleChange
+	LFS       *LFSChange
+	// BinarySizes is set for binary files by callers that can read the blobs
+	BinarySizes *BinarySizes
+	// Source is set by callers that combine several diffs; Parse leaves it empty
+	Source Source
+
+	// Header holds the lines before the first hunk as git printed them
+	Header []string
+	Hunks  []Hunk
+}
+
+// Path returns the path the file has after the change, or its old path if it was deleted
+func (f *File) Path() string {
+	if f.NewPath != "" {
+		return f.NewPath
+	}
+	return f.OldPath
+}
+
+// DisplayPath returns the path, showing both names for renames and copies
+func (f *File) DisplayPath() string {
+	if (f.IsRename || f.IsCopy) && f.OldPath != f.NewPath {
+		return f.OldPath + " => " + f.NewPath
+	}
+	return f.Path()
+}
+
+// Label returns the display path followed by the source in brackets, if there is one
+func (f *File) Label() string {
+	if f.Source == "" {
+		return f.DisplayPath()
+	}
+	return fmt.Sprintf("%s [%s]", f.DisplayPath(), f.Source)
+}
+
+// SetSource labels every file with source
+func SetSource(files []*File, source Source) []*File {
+	for _, f := range files {
+		f.Source = source
+	}
+	return files
+}
+
+// ModeChanged reports whether the change touches the file mode
+func (f *File) ModeChanged() bool {
+	return f.OldMode != "" && f.NewMode != "" && f.OldMode != f.NewMode
+}
+
+// Counts returns the number of added and removed lines
+func (f *File) Counts() (added, removed int) {
+	for _, h := range f.Hunks {
+		for _, l := range h.Lines {
+			switch l.Kind {
+			case Added:
+				added++
+			case Removed:
+				removed++
+			}
+		}
+	}
+	return added, removed
+}
+
+// String renders the file as unified diff text, ending in a newline
+func (f *File) String() string {
+	var sb strings.Builder
+	for _, line := range f.Header {
+		sb.WriteString(line)
+		sb.WriteString("\n")
+	}
+	for _, h := range f.Hunks {
+		sb.WriteString(h.Header())
+		sb.WriteString("\n")
+		for _, l := range h.Lines {
+			sb.WriteString(l.Kind.Prefix())
+			sb.WriteString(l.Content)
+			sb.WriteString("\n")
+			if l.NoNewline {
+				sb.WriteString("\\ No newline at end of file\n")
+			}
+		}
+	}
+	return sb.String()
+}
+
+// Format renders files as unified diff text
+func Format(files []*File) string {
+	var sb strings.Builder
+	for _, f := range files {
+		sb.WriteString(f.String())
+	}
+	return sb.String()
+}
+
+// Parse reads the output of git diff. Paths are expected with the default a/ and b/
+// prefixes; quoted paths are unquoted.
+func Parse(text string) ([]*File, error) {
+	var files []*File
+	var file *File
+	var hunk *Hunk
+	// remaining lines of the current hunk on each side
+	oldLeft, newLeft := 0, 0
+	oldLine, newLine := 0, 0
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		line := scanner.Text()
+		lineNo++
+
+		if hunk != nil && (oldLeft > 0 || newLeft > 0) {
+			// Some tools strip the space off empty context lines
+			if line == "" {
+				line = " "
+			}
+			l := Line{Content: line[1:]}
+			switch line[0] {
+			case ' ':
+				l.Kind, l.OldLine, l.NewLine = Context, oldLine, newLine
+				oldLine, newLine = oldLine+1, newLine+1
+				oldLeft, newLeft = oldLeft-1, newLeft-1
+			case '-':
+				l.Kind, l.OldLine = Removed, oldLine
+				oldLine++
+				oldLeft--
+			case '+':
+				l.Kind, l.NewLine = Added, newLine
+				newLine++
+				newLeft--
+			case '\\':
+				markNoNewline(hunk)
+				continue
+			default:
+				return nil, fmt.Errorf("line %d: unexpected line in hunk: %q", lineNo, line)
+			}
+			if oldLeft < 0 || newLeft < 0 {
+				return nil, fmt.Errorf("line %d: hunk is longer than its header says", lineNo)
+			}
+			hunk.Lines = append(hunk.Lines, l)
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			oldPath, newPath := splitGitHeader(strings.TrimPrefix(line, "diff --git "))
+			file = &File{OldPath: oldPath, NewPath: newPath, Header: []string{line}}
+			files = append(files, file)
+			hunk = nil
+
+		case file == nil:
+			// Anything before the first file, such as commit headers, is skipped
+
+		case strings.HasPrefix(line, "@@ "):
+			h, err := parseHunkHeader(line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			file.Hunks = append(file.Hunks, h)
+			hunk = &file.Hunks[len(file.Hunks)-1]
+			oldLeft, newLeft = h.OldLines, h.NewLines
+			oldLine, newLine = h.OldStart, h.NewStart
+
+		case line == `\ No newline at end of file` && hunk != nil:
+			markNoNewline(hunk)
+
+		case hunk != nil:
+			return nil, fmt.Errorf("line %d: unexpected line after hunk: %q", lineNo, line)
+
+		default:
+			file.Header = append(file.Header, line)
+			parseHeaderLine(file, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if hunk != nil && (oldLeft > 0 || newLeft > 0) {
+		return nil, fmt.Errorf("diff ends in the middle of a hunk")
+	}
+
+	for _, f := range files {
+		if f.IsNew {
+			f.OldPath = ""
+		}
+		if f.IsDeleted {
+			f.NewPath = ""
+		}
+		classify(f)
+	}
+	return files, nil
+}
+
+// markNoNewline flags the last line of a hunk as missing its final newline
+func markNoNewline(h *Hunk) {
+	if len(h.Lines) > 0 {
+		h.Lines[len(h.Lines)-1].NoNewline = true
+	}
+}
+
+// parseHeaderLine fills in file details from one extended header line
+func parseHeaderLine(f *File, line string) {
+	switch {
+	case strings.HasPrefix(line, "old mode "):
+		f.OldMode = strings.TrimPrefix(line, "old mode ")
+	case strings.HasPrefix(line, "new mode "):
+		f.NewMode = strings.TrimPrefix(line, "new mode ")
+	case strings.HasPrefix(line, "new file mode "):
+		f.IsNew = true
+		f.NewMode = strings.TrimPrefix(line, "new file mode ")
+	case strings.HasPrefix(line, "deleted file mode "):
+		f.IsDeleted = true
+		f.OldMode = strings.TrimPrefix(line, "deleted file mode ")
+	case strings.HasPrefix(line, "similarity index "):
+		f.Similarity, _ = strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(line, "similarity index "), "%"))
+	case strings.HasPrefix(line, "rename from "):
+		f.IsRename = true
+		f.OldPath = unquote(strings.TrimPrefix(line, "rename from "))
+	case strings.HasPrefix(line, "rename to "):
+		f.IsRename = true
+		f.NewPath = unquote(strings.TrimPrefix(line, "rename to "))
+	case strings.HasPrefix(line, "copy from "):
+		f.IsCopy = true
+		f.OldPath = unquote(strings.TrimPrefix(line, "copy from "))
+	case strings.HasPrefix(line, "copy to "):
+		f.IsCopy = true
+		f.NewPath = unquote(strings.TrimPrefix(line, "copy to "))
+	case strings.HasPrefix(line, "index "):
+		// index <old>..<new>[ <mode>]
+		fields := strings.Fields(strings.TrimPrefix(line, "index "))
+		if len(fields) == 0 {
+			return
+		}
+		f.OldHash, f.NewHash, _ = strings.Cut(fields[0], "..")
+		if len(fields) > 1 && f.OldMode == "" && f.NewMode == "" {
+			f.OldMode, f.NewMode = fields[1], fields[1]
+		}
+	case strings.HasPrefix(line, "Binary files ") || line == "GIT binary patch":
+		f.IsBinary = true
+	case strings.HasPrefix(line, "--- "):
+		// git ends paths containing spaces with a tab
+		if path := stripPrefix(unquote(strings.TrimSuffix(strings.TrimPrefix(line, "--- "), "\t")), "a/"); path != DevNull {
+			f.OldPath = path
+		}
+	case strings.HasPrefix(line, "+++ "):
+		if path := stripPrefix(unquote(strings.TrimSuffix(strings.TrimPrefix(line, "+++ "), "\t")), "b/"); path != DevNull {
+			f.NewPath = path
+		}
+	}
+}
+
+// parseHunkHeader parses "@@ -<old start>[,<old lines>] +<new start>[,<new lines>] @@ [section]"
+func parseHunkHeader(line string) (Hunk, error) {
+	rest := strings.TrimPrefix(line, "@@ ")
+	ranges, section, ok := strings.Cut(rest, " @@")
+	fields := strings.Fields(ranges)
+	if !ok || len(fields) != 2 || !strings.HasPrefix(fields[0], "-") || !strings.HasPrefix(fields[1], "+") {
+		return Hunk{}, fmt.Errorf("invalid hunk header %q", line)
+	}
+
+	var h Hunk
+	var err error
+	if h.OldStart, h.OldLines, err = parseRange(fields[0][1:]); err != nil {
+		return Hunk{}, fmt.Errorf("invalid hunk header %q: %w", line, err)
+	}
+	if h.NewStart, h.NewLines, err = parseRange(fields[1][1:]); err != nil {
+		return Hunk{}, fmt.Errorf("invalid hunk header %q: %w", line, err)
+	}
+	h.Section = strings.TrimPrefix(section, " ")
+	return h, nil
+}
+
+// parseRange parses "<start>[,<lines>]"; a missing line count means one line
+func parseRange(s string) (start, lines int, err error) {
+	startText, linesText, hasLines := strings.Cut(s, ",")
+	if start, err = strconv.Atoi(startText); err != nil {
+		return 0, 0, err
+	}
+	lines = 1
+	if hasLines {
+		if lines, err = strconv.Atoi(linesText); err != nil {
+			return 0, 0, err
+		}
+	}
+	return start, lines, nil
+}
+
+// splitGitHeader returns the paths of a "diff --git a/<old> b/<new>" line. Unquoted
+// paths with spaces are ambiguous, so they are split where both halves name the same
+// file; renames get their real paths from the rename lines later.
+func splitGitHeader(s string) (oldPath, newPath string) {
+	if strings.HasPrefix(s, `"`) {
+		if end := closingQuote(s); end > 0 {
+			oldPath, s = unquote(s[:end+1]), strings.TrimPrefix(s[end+1:], " ")
+			return stripPrefix(oldPath, "a/"), stripPrefix(unquote(s), "b/")
+		}
+	}
+	if strings.HasSuffix(s, `"`) {
+		if i := strings.Index(s, ` "`); i >= 0 {
+			return stripPrefix(s[:i], "a/"), stripPrefix(unquote(s[i+1:]), "b/")
+		}
+	}
+	if len(s)%2 == 1 {
+		half := len(s) / 2
+		if s[half] == ' ' && strings.TrimPrefix(s[:half], "a/") == strings.TrimPrefix(s[half+1:], "b/") {
+			return stripPrefix(s[:half], "a/"), stripPrefix(s[half+1:], "b/")
+		}
+	}
+	oldPath, newPath, _ = strings.Cut(s, " b/")
+	return stripPrefix(oldPath, "a/"), newPath
+}
+
+// closingQuote returns the index of the quote ending the quoted string s starts with
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// unquote decodes a path git quoted because of unusual characters
+func unquote(path string) string {
+	if len(path) < 2 || !strings.HasPrefix(path, `"`) || !strings.HasSuffix(path, `"`) {
+		return path
+	}
+	if unquoted, err := strconv.Unquote(path); err == nil {
+		return unquoted
+	}
+	return path
+}
+
+func stripPrefix(path, prefix string) string {
+	if path == DevNull {
+		return path
+	}
+	return strings.TrimPrefix(path, prefix)
+}
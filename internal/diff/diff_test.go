@@ -0,0 +1,394 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+// lines joins diff lines, ending each with a newline like git's output
+func lines(l ...string) string {
+	return strings.Join(l, "\n") + "\n"
+}
+
+// Fixtures are real git diff output, in the format git.Repo asks for
+var (
+	modifiedDiff = lines(
+		`diff --git a/main.go b/main.go`,
+		`index d85acd0..1b1d05f 100644`,
+		`--- a/main.go`,
+		`+++ b/main.go`,
+		`@@ -4,12 +4,12 @@ import "fmt"`,
+		` `,
+		` func main() {`,
+		`     fmt.Println("a")`,
+		`-    fmt.Println("b")`,
+		`+    fmt.Println("B")`,
+		`     fmt.Println("c")`,
+		`     fmt.Println("d")`,
+		`     fmt.Println("e")`,
+		`     fmt.Println("f")`,
+		`     fmt.Println("g")`,
+		`-    fmt.Println("h")`,
+		`+    fmt.Println("H")`,
+		`     fmt.Println("i")`,
+		` }`,
+	)
+	renamedDiff = lines(
+		`diff --git a/old.txt b/new.txt`,
+		`similarity index 91%`,
+		`rename from old.txt`,
+		`rename to new.txt`,
+		`index c9e9e05..edf6008 100644`,
+		`--- a/old.txt`,
+		`+++ b/new.txt`,
+		`@@ -7,4 +7,4 @@ six`,
+		` seven`,
+		` eight`,
+		` nine`,
+		`-ten`,
+		`+TEN`,
+	)
+	pureRenameDiff = lines(
+		`diff --git a/new.txt "b/dir/na\303\257ve file.txt"`,
+		`similarity index 100%`,
+		`rename from new.txt`,
+		`rename to "dir/na\303\257ve file.txt"`,
+	)
+	quotedDiff = lines(
+		`diff --git "a/caf\303\251.txt" "b/caf\303\251.txt"`,
+		`index 45b983b..9d2a300 100644`,
+		`--- "a/caf\303\251.txt"`,
+		`+++ "b/caf\303\251.txt"`,
+		`@@ -1 +1,2 @@`,
+		` hi`,
+		`+ho`,
+	)
+	spacesDiff = lines(
+		`diff --git a/my file.txt b/my file.txt`,
+		`index b478595..0a1a246 100644`,
+		"--- a/my file.txt\t",
+		"+++ b/my file.txt\t",
+		`@@ -1 +1,2 @@`,
+		` s`,
+		`+t`,
+	)
+	binaryDiff = lines(
+		`diff --git a/img.bin b/img.bin`,
+		`index 8352675..a903574 100644`,
+		`Binary files a/img.bin and b/img.bin differ`,
+	)
+	newBinaryDiff = lines(
+		`diff --git a/new.bin b/new.bin`,
+		`new file mode 100644`,
+		`index 0000000..00ffac9`,
+		`Binary files /dev/null and b/new.bin differ`,
+	)
+	modeDiff = lines(
+		`diff --git a/run.sh b/run.sh`,
+		`old mode 100644`,
+		`new mode 100755`,
+	)
+	noNewlineDiff = lines(
+		`diff --git a/nonl.txt b/nonl.txt`,
+		`index c1b0730..e25f181 100644`,
+		`--- a/nonl.txt`,
+		`+++ b/nonl.txt`,
+		`@@ -1 +1 @@`,
+		`-x`,
+		`\ No newline at end of file`,
+		`+y`,
+		`\ No newline at end of file`,
+	)
+	deletedDiff = lines(
+		`diff --git a/gone.txt b/gone.txt`,
+		`deleted file mode 100644`,
+		`index 286c5f5..0000000`,
+		`--- a/gone.txt`,
+		`+++ /dev/null`,
+		`@@ -1 +0,0 @@`,
+		`-gone`,
+	)
+	newDiff = lines(
+		`diff --git a/fresh.txt b/fresh.txt`,
+		`new file mode 100644`,
+		`index 0000000..92d5444`,
+		`--- /dev/null`,
+		`+++ b/fresh.txt`,
+		`@@ -0,0 +1 @@`,
+		`+fresh`,
+	)
+)
+
+// fileSummary is what TestParse checks of each parsed file
+type fileSummary struct {
+	OldPath, NewPath string
+	OldMode, NewMode string
+	IsNew, IsDeleted bool
+	IsRename         bool
+	Similarity       int
+	IsBinary         bool
+	Hunks            int
+	Added, Removed   int
+}
+
+func summarize(f *File) fileSummary {
+	added, removed := f.Counts()
+	return fileSummary{
+		OldPath: f.OldPath, NewPath: f.NewPath,
+		OldMode: f.OldMode, NewMode: f.NewMode,
+		IsNew: f.IsNew, IsDeleted: f.IsDeleted,
+		IsRename:   f.IsRename,
+		Similarity: f.Similarity,
+		IsBinary:   f.IsBinary,
+		Hunks:      len(f.Hunks),
+		Added:      added, Removed: removed,
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want fileSummary
+	}{
+		{
+			name: "modified",
+			text: modifiedDiff,
+			want: fileSummary{OldPath: "main.go", NewPath: "main.go", OldMode: "100644", NewMode: "100644", Hunks: 1, Added: 2, Removed: 2},
+		},
+		{
+			name: "renamed with changes",
+			text: renamedDiff,
+			want: fileSummary{OldPath: "old.txt", NewPath: "new.txt", OldMode: "100644", NewMode: "100644", IsRename: true, Similarity: 91, Hunks: 1, Added: 1, Removed: 1},
+		},
+		{
+			name: "renamed to a quoted non-ASCII path",
+			text: pureRenameDiff,
+			want: fileSummary{OldPath: "new.txt", NewPath: "dir/naïve file.txt", IsRename: true, Similarity: 100},
+		},
+		{
+			name: "quoted non-ASCII path",
+			text: quotedDiff,
+			want: fileSummary{OldPath: "café.txt", NewPath: "café.txt", OldMode: "100644", NewMode: "100644", Hunks: 1, Added: 1},
+		},
+		{
+			name: "path with spaces",
+			text: spacesDiff,
+			want: fileSummary{OldPath: "my file.txt", NewPath: "my file.txt", OldMode: "100644", NewMode: "100644", Hunks: 1, Added: 1},
+		},
+		{
+			name: "binary",
+			text: binaryDiff,
+			want: fileSummary{OldPath: "img.bin", NewPath: "img.bin", OldMode: "100644", NewMode: "100644", IsBinary: true},
+		},
+		{
+			name: "new binary",
+			text: newBinaryDiff,
+			want: fileSummary{NewPath: "new.bin", NewMode: "100644", IsNew: true, IsBinary: true},
+		},
+		{
+			name: "mode only",
+			text: modeDiff,
+			want: fileSummary{OldPath: "run.sh", NewPath: "run.sh", OldMode: "100644", NewMode: "100755"},
+		},
+		{
+			name: "no newline at end of file",
+			text: noNewlineDiff,
+			want: fileSummary{OldPath: "nonl.txt", NewPath: "nonl.txt", OldMode: "100644", NewMode: "100644", Hunks: 1, Added: 1, Removed: 1},
+		},
+		{
+			name: "deleted",
+			text: deletedDiff,
+			want: fileSummary{OldPath: "gone.txt", OldMode: "100644", IsDeleted: true, Hunks: 1, Removed: 1},
+		},
+		{
+			name: "new",
+			text: newDiff,
+			want: fileSummary{NewPath: "fresh.txt", NewMode: "100644", IsNew: true, Hunks: 1, Added: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := Parse(tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(files) != 1 {
+				t.Fatalf("Parse() returned %d files, want 1", len(files))
+			}
+			if got := summarize(files[0]); got != tt.want {
+				t.Errorf("Parse() = %+v\nwant      %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseModeChanged(t *testing.T) {
+	for _, tt := range []struct {
+		text string
+		want bool
+	}{
+		{modeDiff, true},
+		{modifiedDiff, false},
+		{newDiff, false},
+	} {
+		files, err := Parse(tt.text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := files[0].ModeChanged(); got != tt.want {
+			t.Errorf("ModeChanged() of %s = %v, want %v", files[0].Path(), got, tt.want)
+		}
+	}
+}
+
+func TestParseLineNumbers(t *testing.T) {
+	files, err := Parse(modifiedDiff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hunk := files[0].Hunks[0]
+	if hunk.Section != `import "fmt"` {
+		t.Errorf("Section = %q, want %q", hunk.Section, `import "fmt"`)
+	}
+
+	want := []struct {
+		kind             LineKind
+		oldLine, newLine int
+	}{
+		{Context, 4, 4},
+		{Context, 5, 5},
+		{Context, 6, 6},
+		{Removed, 7, 0},
+		{Added, 0, 7},
+		{Context, 8, 8},
+		{Context, 9, 9},
+		{Context, 10, 10},
+		{Context, 11, 11},
+		{Context, 12, 12},
+		{Removed, 13, 0},
+		{Added, 0, 13},
+		{Context, 14, 14},
+		{Context, 15, 15},
+	}
+	if len(hunk.Lines) != len(want) {
+		t.Fatalf("hunk has %d lines, want %d", len(hunk.Lines), len(want))
+	}
+	for i, w := range want {
+		l := hunk.Lines[i]
+		if l.Kind != w.kind || l.OldLine != w.oldLine || l.NewLine != w.newLine {
+			t.Errorf("line %d = {%v %d %d}, want {%v %d %d}", i, l.Kind, l.OldLine, l.NewLine, w.kind, w.oldLine, w.newLine)
+		}
+	}
+	if hunk.Lines[0].Content != "" {
+		t.Errorf("empty context line has content %q", hunk.Lines[0].Content)
+	}
+}
+
+func TestParseNoNewline(t *testing.T) {
+	files, err := Parse(noNewlineDiff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range files[0].Hunks[0].Lines {
+		if !l.NoNewline {
+			t.Errorf("%s%s isn't marked as missing its newline", l.Kind.Prefix(), l.Content)
+		}
+	}
+}
+
+func TestParseSkipsCommitHeaders(t *testing.T) {
+	text := lines(`commit 0123456789abcdef`, `Author: t <t@t>`, ``, `    message`, ``) + modifiedDiff + newDiff
+	files, err := Parse(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || files[0].Path() != "main.go" || files[1].Path() != "fresh.txt" {
+		t.Errorf("Parse() returned %d files", len(files))
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		wantErr string
+	}{
+		{
+			name:    "invalid hunk header",
+			text:    lines(`diff --git a/x b/x`, `--- a/x`, `+++ b/x`, `@@ -1,a +1 @@`, `-x`, `+y`),
+			wantErr: "invalid hunk header",
+		},
+		{
+			name:    "hunk longer than its header",
+			text:    lines(`diff --git a/x b/x`, `--- a/x`, `+++ b/x`, `@@ -1 +1 @@`, `-x`, `-y`, `+z`),
+			wantErr: "hunk is longer than its header says",
+		},
+		{
+			name:    "truncated hunk",
+			text:    lines(`diff --git a/x b/x`, `--- a/x`, `+++ b/x`, `@@ -1,2 +1,2 @@`, ` x`),
+			wantErr: "diff ends in the middle of a hunk",
+		},
+		{
+			name:    "unexpected line in hunk",
+			text:    lines(`diff --git a/x b/x`, `--- a/x`, `+++ b/x`, `@@ -1 +1 @@`, `*x`),
+			wantErr: "unexpected line in hunk",
+		},
+		{
+			name:    "unexpected line after hunk",
+			text:    lines(`diff --git a/x b/x`, `--- a/x`, `+++ b/x`, `@@ -1 +1 @@`, `-x`, `+y`, `index 1..2`),
+			wantErr: "unexpected line after hunk",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.text)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Parse() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFormatRoundTrip(t *testing.T) {
+	all := []string{
+		modifiedDiff, renamedDiff, pureRenameDiff, quotedDiff, spacesDiff, binaryDiff,
+		newBinaryDiff, modeDiff, noNewlineDiff, deletedDiff, newDiff,
+	}
+	// Each diff alone, then all of them in one
+	texts := append(all, strings.Join(all, ""))
+
+	for _, text := range texts {
+		files, err := Parse(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := Format(files); got != text {
+			t.Errorf("Format(Parse()) changed the diff:\ngot:\n%s\nwant:\n%s", got, text)
+		}
+	}
+}
+
+func TestSplitGitHeader(t *testing.T) {
+	tests := []struct {
+		header           string
+		oldPath, newPath string
+	}{
+		{`a/main.go b/main.go`, "main.go", "main.go"},
+		{`a/old.txt b/new.txt`, "old.txt", "new.txt"},
+		{`a/my file.txt b/my file.txt`, "my file.txt", "my file.txt"},
+		{`a/a b/c b/a b/c`, "a b/c", "a b/c"},
+		{`"a/caf\303\251.txt" "b/caf\303\251.txt"`, "café.txt", "café.txt"},
+		{`a/new.txt "b/dir/na\303\257ve file.txt"`, "new.txt", "dir/naïve file.txt"},
+		{`"a/tab\there.txt" b/plain.txt`, "tab\there.txt", "plain.txt"},
+	}
+
+	for _, tt := range tests {
+		oldPath, newPath := splitGitHeader(tt.header)
+		if oldPath != tt.oldPath || newPath != tt.newPath {
+			t.Errorf("splitGitHeader(%q) = %q, %q, want %q, %q", tt.header, oldPath, newPath, tt.oldPath, tt.newPath)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package diff
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SubmoduleMode is the mode git records for a submodule (a gitlink)
+const SubmoduleMode = "160000"
+
+// lfsVersion starts every Git LFS pointer file
+const lfsVersion = "version https://git-lfs.github.com/spec/"
+
+// SubmoduleChange is a change to the commit a submodule points at. A side is empty when
+// the submodule was added or removed.
+type SubmoduleChange struct {
+	Old string
+	New string
+}
+
+// LFSPointer is the content of a Git LFS pointer file
+type LFSPointer struct {
+	// OID is the SHA-256 of the object, without the "sha256:" prefix
+	OID  string
+	Size int64
+}
+
+// LFSChange is a change to a file stored with Git LFS. A side is nil when the file was
+// added or removed, or when it wasn't a pointer on that side.
+type LFSChange struct {
+	Old *LFSPointer
+	New *LFSPointer
+}
+
+// BinarySizes are the sizes of both sides of a binary file in bytes, zero for a side
+// that doesn't exist
+type BinarySizes struct {
+	Old int64
+	New int64
+}
+
+// IsSpecial reports whether the file's diff lines aren't code: submodules, LFS pointers
+// and binary files. These are shown as a one-line summary instead.
+func (f *File) IsSpecial() bool {
+	return f.Submodule != nil || f.LFS != nil || f.IsBinary
+}
+
+// Summary describes a submodule, LFS or binary change in one line, or returns "" for
+// ordinary files
+func (f *File) Summary() string {
+	detail := f.summaryDetail()
+	switch {
+	case f.Submodule != nil:
+		return "submodule " + detail
+	case f.LFS != nil:
+		return "LFS object " + detail
+	case f.IsBinary && detail == "":
+		return "binary file changed"
+	case f.IsBinary:
+		return "binary " + detail
+	}
+	return ""
+}
+
+// summaryDetail describes the two sides of a submodule, LFS or binary change, or returns
+// "" when they aren't known
+func (f *File) summaryDetail() string {
+	switch {
+	case f.Submodule != nil:
+		return change(shortCommit(f.Submodule.Old), shortCommit(f.Submodule.New))
+	case f.LFS != nil:
+		var oldText, newText string
+		var oldSize, newSize int64
+		if f.LFS.Old != nil {
+			oldText, oldSize = FormatSize(f.LFS.Old.Size), f.LFS.Old.Size
+		}
+		if f.LFS.New != nil {
+			newText, newSize = FormatSize(f.LFS.New.Size), f.LFS.New.Size
+		}
+		return change(oldText, newText) + sizeDelta(oldSize, newSize, oldText != "" && newText != "")
+	case f.IsBinary && f.BinarySizes != nil:
+		var oldText, newText string
+		if !f.IsNew {
+			oldText = FormatSize(f.BinarySizes.Old)
+		}
+		if !f.IsDeleted {
+			newText = FormatSize(f.BinarySizes.New)
+		}
+		return change(oldText, newText) + sizeDelta(f.BinarySizes.Old, f.BinarySizes.New, oldText != "" && newText != "")
+	}
+	return ""
+}
+
+// change renders the two sides of a change, either of which may be missing
+func change(oldText, newText string) string {
+	switch {
+	case oldText == "":
+		return "added (" + newText + ")"
+	case newText == "":
+		return "removed (was " + oldText + ")"
+	default:
+		return oldText + " → " + newText
+	}
+}
+
+// sizeDelta renders how much a size grew or shrank, when both sides exist and differ
+func sizeDelta(oldSize, newSize int64, both bool) string {
+	switch {
+	case !both || oldSize == newSize:
+		return ""
+	case newSize > oldSize:
+		return " (+" + FormatSize(newSize-oldSize) + ")"
+	default:
+		return " (-" + FormatSize(oldSize-newSize) + ")"
+	}
+}
+
+// shortCommit abbreviates a submodule commit, keeping suffixes such as "-dirty"
+func shortCommit(commit string) string {
+	hash, suffix, _ := strings.Cut(commit, "-")
+	if len(hash) > 7 {
+		hash = hash[:7]
+	}
+	if suffix != "" {
+		return hash + "-" + suffix
+	}
+	return hash
+}
+
+// FormatSize renders a byte count with a binary unit, like "512 B" or "1.5 KiB"
+func FormatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, exp := float64(n)/unit, 0
+	for value >= unit && exp < 3 {
+		value /= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", value, "KMGT"[exp])
+}
+
+// classify recognizes submodule and LFS changes from the file's modes and hunk lines
+func classify(f *File) {
+	if f.OldMode == SubmoduleMode || f.NewMode == SubmoduleMode {
+		sub := &SubmoduleChange{}
+		for _, h := range f.Hunks {
+			for _, l := range h.Lines {
+				commit, ok := strings.CutPrefix(l.Content, "Subproject commit ")
+				if !ok {
+					continue
+				}
+				switch l.Kind {
+				case Removed:
+					sub.Old = commit
+				case Added:
+					sub.New = commit
+				default:
+					sub.Old, sub.New = commit, commit
+				}
+			}
+		}
+		f.Submodule = sub
+		return
+	}
+	if f.IsBinary || len(f.Hunks) == 0 {
+		return
+	}
+
+	var oldLines, newLines []string
+	for _, h := range f.Hunks {
+		for _, l := range h.Lines {
+			if l.Kind != Added {
+				oldLines = append(oldLines, l.Content)
+			}
+			if l.Kind != Removed {
+				newLines = append(newLines, l.Content)
+			}
+		}
+	}
+	oldPointer, newPointer := ParseLFSPointer(oldLines), ParseLFSPointer(newLines)
+	if oldPointer != nil || newPointer != nil {
+		f.LFS = &LFSChange{Old: oldPointer, New: newPointer}
+	}
+}
+
+// ParseLFSPointer reads the lines of a Git LFS pointer file, returning nil if they
+// aren't one
+func ParseLFSPointer(lines []string) *LFSPointer {
+	if len(lines) == 0 || !strings.HasPrefix(lines[0], lfsVersion) {
+		return nil
+	}
+	var pointer LFSPointer
+	for _, line := range lines[1:] {
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "oid":
+			pointer.OID = strings.TrimPrefix(value, "sha256:")
+		case "size":
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil
+			}
+			pointer.Size = size
+		}
+	}
+	if pointer.OID == "" {
+		return nil
+	}
+	return &pointer
+}
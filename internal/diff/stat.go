@@ -0,0 +1,101 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// minGraphWidth keeps the +/- graph readable when names are long
+const minGraphWidth = 10
+
+// Stat renders a summary of the changes like git diff --stat, fitting lines into width
+// columns where it can
+func Stat(files []*File, width int) string {
+	if len(files) == 0 {
+		return ""
+	}
+
+	type row struct {
+		name           string
+		count          string
+		detail         string // replaces the graph for submodules, LFS and binary files
+		added, removed int
+	}
+	rows := make([]row, len(files))
+	nameWidth, countWidth, maxChanged := 0, 0, 0
+	totalAdded, totalRemoved := 0, 0
+	for i, f := range files {
+		r := row{name: f.Label()}
+		switch {
+		case f.Submodule != nil:
+			r.count, r.detail = "Sub", f.summaryDetail()
+		case f.LFS != nil:
+			r.count, r.detail = "LFS", f.summaryDetail()
+		case f.IsBinary:
+			r.count, r.detail = "Bin", f.summaryDetail()
+		default:
+			r.added, r.removed = f.Counts()
+			r.count = fmt.Sprint(r.added + r.removed)
+			maxChanged = max(maxChanged, r.added+r.removed)
+		}
+		totalAdded += r.added
+		totalRemoved += r.removed
+		nameWidth = max(nameWidth, utf8.RuneCountInString(r.name))
+		countWidth = max(countWidth, len(r.count))
+		rows[i] = r
+	}
+
+	// " <name> | <count> <graph>", shortening names before the graph
+	graphWidth := min(maxChanged, width-nameWidth-countWidth-4)
+	if graphWidth < minGraphWidth && maxChanged > graphWidth {
+		graphWidth = min(maxChanged, minGraphWidth)
+		nameWidth = min(nameWidth, max(width-graphWidth-countWidth-4, 10))
+	}
+
+	var sb strings.Builder
+	for _, r := range rows {
+		name := []rune(r.name)
+		if len(name) > nameWidth {
+			name = append([]rune("..."), name[len(name)-nameWidth+3:]...)
+		}
+		padding := strings.Repeat(" ", nameWidth-len(name))
+		fmt.Fprintf(&sb, " %s%s | %*s", string(name), padding, countWidth, r.count)
+		if r.detail != "" {
+			sb.WriteString(" " + r.detail)
+		} else if changed := r.added + r.removed; changed > 0 {
+			plus, minus := r.added, r.removed
+			if maxChanged > graphWidth {
+				plus = scale(r.added, maxChanged, graphWidth)
+				minus = scale(r.removed, maxChanged, graphWidth)
+			}
+			sb.WriteString(" " + strings.Repeat("+", plus) + strings.Repeat("-", minus))
+		}
+		sb.WriteString("\n")
+	}
+
+	summary := fmt.Sprintf(" %d %s changed", len(files), plural(len(files), "file", "files"))
+	if totalAdded > 0 || totalRemoved == 0 {
+		summary += fmt.Sprintf(", %d %s(+)", totalAdded, plural(totalAdded, "insertion", "insertions"))
+	}
+	if totalRemoved > 0 || totalAdded == 0 {
+		summary += fmt.Sprintf(", %d %s(-)", totalRemoved, plural(totalRemoved, "deletion", "deletions"))
+	}
+	sb.WriteString(summary)
+	return sb.String()
+}
+
+// scale shrinks a change count to a graph width, keeping at least one mark for any change
+func scale(n, maxChanged, width int) int {
+	if n == 0 {
+		return 0
+	}
+	return max(1, n*width/maxChanged)
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
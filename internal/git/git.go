@@ -1,60 +1,98 @@
 package git
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
 
-	"github.com/joaosaffran/mob/internal/shell"
+	"github.com/joaosaffran/mob/internal/diff"
 )
 
-// Run executes a git command with stdout and stderr connected to the terminal
-func Run(args ...string) error {
-	return shell.Run("git", args...)
+// Checkout switches to the specified branch
+func (r *Repo) Checkout(branch string) error {
+	return r.Run("checkout", branch)
 }
 
-// Output executes a git command and returns the output
-func Output(args ...string) (string, error) {
-	output, err := shell.Output("git", args...)
-	if err != nil {
-		return "", err
-	}
-	return strings.TrimSpace(string(output)), nil
+// CheckoutNewBranch creates and switches to a new branch
+func (r *Repo) CheckoutNewBranch(branch string) error {
+	return r.Run("checkout", "-b", branch)
 }
 
-// Checkout switches to the specified branch
-func Checkout(branch string) error {
-	return Run("checkout", branch)
+// Pull fetches and merges the latest changes
+func (r *Repo) Pull() error {
+	return r.Run("pull")
 }
 
-// CheckoutNewBranch creates and switches to a new branch
-func CheckoutNewBranch(branch string) error {
-	return Run("checkout", "-b", branch)
+// Fetch downloads objects and refs from a remote
+func (r *Repo) Fetch(remote string) error {
+	return r.Run("fetch", remote)
 }
 
-// Pull fetches and merges the latest changes
-func Pull() error {
-	return Run("pull")
+// RebaseOnto replays the commits of branch since upstream on top of newBase
+func (r *Repo) RebaseOnto(newBase, upstream, branch string) error {
+	return r.Run("rebase", "--onto", newBase, upstream, branch)
+}
+
+// AbortRebase aborts an in-progress rebase
+func (r *Repo) AbortRebase() error {
+	return r.Run("rebase", "--abort")
 }
 
 // CurrentBranch returns the current branch name
-func CurrentBranch() (string, error) {
-	return Output("rev-parse", "--abbrev-ref", "HEAD")
+func (r *Repo) CurrentBranch() (string, error) {
+	return r.Output("rev-parse", "--abbrev-ref", "HEAD")
 }
 
 // BranchExists checks if a branch exists
-func BranchExists(branch string) bool {
-	_, err := Output("rev-parse", "--verify", branch)
+func (r *Repo) BranchExists(branch string) bool {
+	_, err := r.Output("rev-parse", "--verify", branch)
+	return err == nil
+}
+
+// AheadBehind counts the commits head has that base doesn't (ahead) and the reverse (behind)
+func (r *Repo) AheadBehind(base, head string) (ahead int, behind int, err error) {
+	output, err := r.Output("rev-list", "--left-right", "--count", fmt.Sprintf("%s...%s", base, head))
+	if err != nil {
+		return 0, 0, err
+	}
+	if _, err := fmt.Sscanf(output, "%d %d", &behind, &ahead); err != nil {
+		return 0, 0, err
+	}
+	return ahead, behind, nil
+}
+
+// IsAncestor reports whether ancestor is reachable from commit
+func (r *Repo) IsAncestor(ancestor, commit string) bool {
+	_, err := r.Output("merge-base", "--is-ancestor", ancestor, commit)
 	return err == nil
 }
 
+// MergeBase returns the best common ancestor of two refs
+func (r *Repo) MergeBase(a, b string) (string, error) {
+	return r.Output("merge-base", a, b)
+}
+
+// RemoteDefaultBranch returns the branch a remote's HEAD points at, as recorded by clone
+func (r *Repo) RemoteDefaultBranch(remote string) (string, error) {
+	output, err := r.Output("symbolic-ref", "--short", fmt.Sprintf("refs/remotes/%s/HEAD", remote))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(output, remote+"/"), nil
+}
+
 // GetCommitHash returns the commit hash for a ref
-func GetCommitHash(ref string) (string, error) {
-	return Output("rev-parse", ref)
+func (r *Repo) GetCommitHash(ref string) (string, error) {
+	return r.Output("rev-parse", ref)
 }
 
 // GetCommitsBetween returns commit hashes between two refs (exclusive base, inclusive head)
-func GetCommitsBetween(base, head string) ([]string, error) {
-	output, err := Output("log", "--format=%H", fmt.Sprintf("%s..%s", base, head))
+func (r *Repo) GetCommitsBetween(base, head string) ([]string, error) {
+	output, err := r.Output("log", "--format=%H", fmt.Sprintf("%s..%s", base, head))
 	if err != nil {
 		return nil, err
 	}
@@ -65,83 +103,316 @@ func GetCommitsBetween(base, head string) ([]string, error) {
 }
 
 // GetCommitMessage returns the commit message for a ref
-func GetCommitMessage(ref string) (string, error) {
-	return Output("log", "-1", "--format=%B", ref)
+func (r *Repo) GetCommitMessage(ref string) (string, error) {
+	return r.Output("log", "-1", "--format=%B", ref)
 }
 
-// CherryPick cherry-picks a commit
-func CherryPick(commit string) error {
-	return Run("cherry-pick", commit)
+// GetCommitParents returns the parent hashes of a commit
+func (r *Repo) GetCommitParents(ref string) ([]string, error) {
+	output, err := r.Output("log", "-1", "--format=%P", ref)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(output), nil
 }
 
-// CommitSquash creates a squash commit with a message
-func CommitSquash(message string) error {
-	return Run("commit", "-m", message)
+// AbortCherryPick aborts an in-progress cherry-pick
+func (r *Repo) AbortCherryPick() error {
+	return r.Run("cherry-pick", "--abort")
 }
 
 // Reset resets to a commit
-func Reset(commit string, mode string) error {
-	return Run("reset", mode, commit)
+func (r *Repo) Reset(commit string, mode string) error {
+	return r.Run("reset", mode, commit)
 }
 
 // StashPush stashes current changes
-func StashPush() error {
-	return Run("stash", "push")
+func (r *Repo) StashPush() error {
+	return r.Run("stash", "push")
 }
 
 // StashPop pops the latest stash
-func StashPop() error {
-	return Run("stash", "pop")
-}
-
-// Merge merges a branch with squash
-func MergeSquash(branch string) error {
-	return Run("merge", "--squash", "-X", "theirs", branch)
+func (r *Repo) StashPop() error {
+	return r.Run("stash", "pop")
 }
 
 // AbortMerge aborts an in-progress merge
-func AbortMerge() error {
-	return Run("merge", "--abort")
+func (r *Repo) AbortMerge() error {
+	return r.Run("merge", "--abort")
 }
 
 // ResetHard resets the current branch to a commit, discarding all changes
-func ResetHard(commit string) error {
-	return Run("reset", "--hard", commit)
+func (r *Repo) ResetHard(commit string) error {
+	return r.Run("reset", "--hard", commit)
+}
+
+// SetBranch points a branch that isn't checked out at a commit
+func (r *Repo) SetBranch(branch, commit string) error {
+	return r.Run("branch", "-f", branch, commit)
+}
+
+// OperationInProgress reports whether a git state file such as MERGE_HEAD or rebase-merge exists
+func (r *Repo) OperationInProgress(name string) bool {
+	path, err := r.Output("rev-parse", "--git-path", name)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(r.path(path))
+	return err == nil
+}
+
+// HasUncommittedChanges reports whether tracked files have staged or unstaged changes.
+// Untracked files don't count, since checkouts and hard resets leave them alone.
+func (r *Repo) HasUncommittedChanges() (bool, error) {
+	output, err := r.Output("status", "--porcelain", "--untracked-files=no")
+	if err != nil {
+		return false, err
+	}
+	return output != "", nil
+}
+
+// HooksDir returns the directory git runs hooks from, which core.hooksPath may move
+func (r *Repo) HooksDir() (string, error) {
+	path, err := r.Output("rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(r.path(path))
+}
+
+// ConfigBool returns a boolean git config value, or def if it isn't set
+func (r *Repo) ConfigBool(key string, def bool) (bool, error) {
+	output, err := r.Output("config", "--type=bool", "--get", key)
+	var gitErr *Error
+	if errors.As(err, &gitErr) && gitErr.ExitCode() == 1 {
+		return def, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return output == "true", nil
+}
+
+// LFSInstalled reports whether the git-lfs extension is available
+func (r *Repo) LFSInstalled() bool {
+	_, err := r.Output("lfs", "version")
+	return err == nil
+}
+
+// HasLFSObject reports whether the local Git LFS store has the object with a SHA-256 oid
+func (r *Repo) HasLFSObject(oid string) bool {
+	if len(oid) < 5 {
+		return false
+	}
+	path, err := r.Output("rev-parse", "--git-path", "lfs/objects/"+oid[:2]+"/"+oid[2:4]+"/"+oid)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(r.path(path))
+	return err == nil
 }
 
 // DeleteBranch deletes a local branch
-func DeleteBranch(branch string) error {
-	return Run("branch", "-D", branch)
+func (r *Repo) DeleteBranch(branch string) error {
+	return r.Run("branch", "-D", branch)
+}
+
+// RenameBranch renames a local branch
+func (r *Repo) RenameBranch(oldName, newName string) error {
+	_, err := r.Output("branch", "-m", oldName, newName)
+	return err
+}
+
+// DeleteRemoteBranch deletes a branch on a remote
+func (r *Repo) DeleteRemoteBranch(remote, branch string) error {
+	_, err := r.Output("push", "--quiet", remote, "--delete", branch)
+	return err
 }
 
 // Push pushes the current branch to the remote
-func Push() error {
-	return Run("push")
+func (r *Repo) Push() error {
+	return r.Run("push")
 }
 
 // PushSetUpstream pushes the current branch and sets the upstream
-func PushSetUpstream(remote, branch string) error {
-	return Run("push", "-u", remote, branch)
+func (r *Repo) PushSetUpstream(remote, branch string) error {
+	return r.Run("push", "-u", remote, branch)
+}
+
+// PushRefspec pushes a refspec to a remote without printing progress
+func (r *Repo) PushRefspec(remote, refspec string) error {
+	_, err := r.Output("push", "--quiet", remote, refspec)
+	return err
 }
 
+// RemoteRefExists checks whether a ref exists on a remote
+func (r *Repo) RemoteRefExists(remote, ref string) (bool, error) {
+	output, err := r.Output("ls-remote", remote, ref)
+	if err != nil {
+		return false, err
+	}
+	return output != "", nil
+}
+
+// FetchRefspec fetches a refspec from a remote without printing progress
+func (r *Repo) FetchRefspec(remote, refspec string) error {
+	_, err := r.Output("fetch", "--quiet", remote, refspec)
+	return err
+}
+
+// PushForceWithLease force-pushes a branch, failing if the remote moved since it was last fetched
+func (r *Repo) PushForceWithLease(remote, branch string) error {
+	return r.Run("push", "--force-with-lease", "-u", remote, branch)
+}
+
+// EditFile opens a file in the editor git is configured to use. Like git, it runs the
+// editor through sh so that quotes and arguments in it work, and ":" leaves the file as is.
+func (r *Repo) EditFile(path string) error {
+	editor, err := r.Output("var", "GIT_EDITOR")
+	if err != nil {
+		return err
+	}
+	switch editor {
+	case "":
+		return fmt.Errorf("no editor configured")
+	case ":":
+		return nil
+	}
+
+	cmd := exec.CommandContext(r.Context(), "sh", "-c", editor+` "$@"`, editor, path)
+	cmd.Dir = r.Dir
+	if len(r.Env) > 0 {
+		cmd.Env = append(os.Environ(), r.Env...)
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("error running editor '%s': %w", editor, err)
+	}
+	return nil
+}
+
+// diffFormat turns off options that would change the format of a diff, such as colors,
+// external diff tools or other path prefixes, so it can be parsed
+var diffFormat = []string{"--no-color", "--no-ext-diff", "-M", "--src-prefix=a/", "--dst-prefix=b/"}
+
 // Diff returns the diff between two refs
-func Diff(base, head string) (string, error) {
-	return Output("diff", base, head)
+func (r *Repo) Diff(base, head string) (string, error) {
+	return r.diff(nil, base, head)
 }
 
-// DiffFiles returns list of changed files between two refs
-func DiffFiles(base, head string) ([]string, error) {
-	output, err := Output("diff", "--name-only", base, head)
+// diff runs git diff in its parseable format with extra arguments
+func (r *Repo) diff(env []string, args ...string) (string, error) {
+	output, err := r.outputBytes(env, nil, append(append([]string{"diff"}, diffFormat...), args...)...)
+	if err != nil {
+		return "", err
+	}
+	return string(output), nil
+}
+
+// DiffFiles returns the changes between two refs, parsed into files, hunks and lines
+func (r *Repo) DiffFiles(base, head string) ([]*diff.File, error) {
+	output, err := r.Diff(base, head)
 	if err != nil {
 		return nil, err
 	}
-	if output == "" {
-		return []string{}, nil
+	return r.parseDiff(output)
+}
+
+// DiffStaged returns the changes staged in the index since HEAD
+func (r *Repo) DiffStaged() ([]*diff.File, error) {
+	output, err := r.diff(nil, "--cached", "HEAD")
+	if err != nil {
+		return nil, err
 	}
-	return strings.Split(output, "\n"), nil
+	return r.parseDiff(output)
+}
+
+// DiffUnstaged returns the changes in the working tree that aren't staged
+func (r *Repo) DiffUnstaged() ([]*diff.File, error) {
+	output, err := r.diff(nil)
+	if err != nil {
+		return nil, err
+	}
+	return r.parseDiff(output)
 }
 
-// DiffStat returns diff statistics between two refs
-func DiffStat(base, head string) (string, error) {
-	return Output("diff", "--stat", base, head)
+// DiffUntracked returns the files git doesn't track and doesn't ignore, as new files. They
+// are marked intent-to-add in an otherwise empty temporary index, so the real one is untouched.
+func (r *Repo) DiffUntracked() ([]*diff.File, error) {
+	output, err := r.outputBytes(nil, nil, "ls-files", "--others", "--exclude-standard", "-z")
+	if err != nil {
+		return nil, err
+	}
+	if len(output) == 0 {
+		return nil, nil
+	}
+	paths := strings.Split(strings.TrimRight(string(output), "\x00"), "\x00")
+
+	idx, err := r.NewEmptyIndex()
+	if err != nil {
+		return nil, err
+	}
+	defer idx.Remove()
+	if _, err := idx.output(nil, append([]string{"add", "--intent-to-add", "--"}, paths...)...); err != nil {
+		return nil, err
+	}
+	text, err := r.diff([]string{"GIT_INDEX_FILE=" + idx.path})
+	if err != nil {
+		return nil, err
+	}
+	return r.parseDiff(text)
+}
+
+// parseDiff parses diff output and looks up the blob sizes of binary files. A side that
+// isn't in the object database, such as an unstaged file, is measured in the working tree.
+func (r *Repo) parseDiff(text string) ([]*diff.File, error) {
+	files, err := diff.Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	var binary []*diff.File
+	var query strings.Builder
+	for _, f := range files {
+		if f.IsBinary && f.Submodule == nil && f.OldHash != "" {
+			binary = append(binary, f)
+			fmt.Fprintf(&query, "%s\n%s\n", f.OldHash, f.NewHash)
+		}
+	}
+	if len(binary) == 0 {
+		return files, nil
+	}
+
+	output, err := r.outputBytes(nil, []byte(query.String()), "cat-file", "--batch-check=%(objectsize)")
+	if err != nil {
+		return nil, err
+	}
+	sizes := strings.Split(strings.TrimSpace(string(output)), "\n")
+	if len(sizes) != 2*len(binary) {
+		return nil, fmt.Errorf("unexpected cat-file output for %d binary files", len(binary))
+	}
+	for i, f := range binary {
+		f.BinarySizes = &diff.BinarySizes{
+			Old: r.blobSize(sizes[2*i], f.OldHash, f.OldPath),
+			New: r.blobSize(sizes[2*i+1], f.NewHash, f.NewPath),
+		}
+	}
+	return files, nil
+}
+
+// blobSize reads one line of cat-file --batch-check output. An all-zero hash is a side
+// that doesn't exist; a missing object is looked up in the working tree.
+func (r *Repo) blobSize(line, hash, path string) int64 {
+	if strings.Trim(hash, "0") == "" {
+		return 0
+	}
+	if size, err := strconv.ParseInt(line, 10, 64); err == nil {
+		return size
+	}
+	if info, err := os.Stat(r.path(path)); err == nil {
+		return info.Size()
+	}
+	return 0
 }
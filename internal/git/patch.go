@@ -0,0 +1,105 @@
+package git
+
+import (
+	"strings"
+	"time"
+)
+
+// PatchCommit pairs a commit with the patch ID of its changes
+type PatchCommit struct {
+	Commit  string
+	PatchID string
+}
+
+// PatchID returns the stable patch ID of the changes between two refs, or an empty
+// string if there are none. Patch IDs ignore line numbers and whitespace, so the same
+// change applied on top of different commits has the same ID. The diffs use diffFormat,
+// so settings such as color.ui or diff.external don't change the IDs.
+func (r *Repo) PatchID(base, head string) (string, error) {
+	diff, err := r.outputBytes(nil, nil, append(append([]string{"diff"}, diffFormat...), base, head)...)
+	if err != nil {
+		return "", err
+	}
+	output, err := r.OutputEnv(nil, diff, "patch-id", "--stable")
+	if err != nil || output == "" {
+		return "", err
+	}
+	return strings.Fields(output)[0], nil
+}
+
+// CommitPatchIDs returns the patch IDs of the non-merge commits between base and head,
+// newest first. Commits without changes are left out.
+func (r *Repo) CommitPatchIDs(base, head string) ([]PatchCommit, error) {
+	args := append(append([]string{"log", "-p"}, diffFormat...), "--no-merges", "--format=commit %H", base+".."+head)
+	log, err := r.outputBytes(nil, nil, args...)
+	if err != nil {
+		return nil, err
+	}
+	return r.patchIDsOf(log)
+}
+
+// PatchIDs returns the patch IDs of the given commits, keyed by commit.
+// Commits without changes have no entry.
+func (r *Repo) PatchIDs(commits []string) (map[string]string, error) {
+	ids := make(map[string]string, len(commits))
+	if len(commits) == 0 {
+		return ids, nil
+	}
+
+	args := append(append([]string{"log", "-p"}, diffFormat...), "--no-walk=unsorted", "--format=commit %H")
+	args = append(args, commits...)
+	log, err := r.outputBytes(nil, nil, args...)
+	if err != nil {
+		return nil, err
+	}
+	patches, err := r.patchIDsOf(log)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range patches {
+		ids[p.Commit] = p.PatchID
+	}
+	return ids, nil
+}
+
+// patchIDsOf runs patch-id over log output that starts each commit with "commit <hash>"
+func (r *Repo) patchIDsOf(log []byte) ([]PatchCommit, error) {
+	output, err := r.OutputEnv(nil, log, "patch-id", "--stable")
+	if err != nil {
+		return nil, err
+	}
+	if output == "" {
+		return []PatchCommit{}, nil
+	}
+
+	var commits []PatchCommit
+	for _, line := range strings.Split(output, "\n") {
+		// <patch-id> <commit>
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		commits = append(commits, PatchCommit{Commit: fields[1], PatchID: fields[0]})
+	}
+	return commits, nil
+}
+
+// FirstCommitOnPath returns the oldest commit of to that descends from from, which is
+// the commit that brought from into to
+func (r *Repo) FirstCommitOnPath(from, to string) (string, error) {
+	output, err := r.Output("rev-list", "--ancestry-path", "--reverse", from+".."+to)
+	if err != nil {
+		return "", err
+	}
+	first, _, _ := strings.Cut(output, "\n")
+	return first, nil
+}
+
+// CommitDate returns the committer date of a commit
+func (r *Repo) CommitDate(ref string) (time.Time, error) {
+	output, err := r.Output("log", "-1", "--format=%cI", ref)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339, output)
+}
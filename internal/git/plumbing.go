@@ -0,0 +1,361 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ZeroHash is the null object name, used as the old value of a ref that must not exist yet
+const ZeroHash = "0000000000000000000000000000000000000000"
+
+// Identity is the name, email and date recorded as a commit's author or committer
+type Identity struct {
+	Name  string
+	Email string
+	Date  string
+}
+
+// CommitOptions controls how CommitTree creates a commit
+type CommitOptions struct {
+	Parents   []string
+	Message   string
+	Author    *Identity
+	Committer *Identity
+	// Sign signs the commit with SigningKey, or user.signingkey if it is empty
+	Sign       bool
+	SigningKey string
+	// SignFormat overrides gpg.format ("openpgp", "x509" or "ssh") when set
+	SignFormat string
+}
+
+// Conflict is a path left unmerged in an index, with the blob and mode of each side.
+// A side is empty when the file doesn't exist there.
+type Conflict struct {
+	Path       string
+	Base       string
+	Ours       string
+	Theirs     string
+	OursMode   string
+	TheirsMode string
+}
+
+// Index is a temporary index used to build trees without touching the working tree
+type Index struct {
+	repo *Repo
+	path string
+}
+
+// TreeOf returns the tree hash of a commit
+func (r *Repo) TreeOf(ref string) (string, error) {
+	return r.Output("rev-parse", ref+"^{tree}")
+}
+
+// CommitTree creates a commit object for a tree and returns its hash
+func (r *Repo) CommitTree(tree string, opts CommitOptions) (string, error) {
+	var args []string
+	if opts.SignFormat != "" {
+		args = append(args, "-c", "gpg.format="+opts.SignFormat)
+	}
+	args = append(args, "commit-tree", tree)
+	for _, parent := range opts.Parents {
+		args = append(args, "-p", parent)
+	}
+	if opts.Sign {
+		if opts.SigningKey != "" {
+			args = append(args, "--gpg-sign="+opts.SigningKey)
+		} else {
+			args = append(args, "--gpg-sign")
+		}
+	}
+
+	env := append(identityEnv("AUTHOR", opts.Author), identityEnv("COMMITTER", opts.Committer)...)
+
+	// The message goes through stdin so it is stored verbatim
+	message := opts.Message
+	if !strings.HasSuffix(message, "\n") {
+		message += "\n"
+	}
+	return r.OutputEnv(env, []byte(message), args...)
+}
+
+// identityEnv returns the GIT_<role>_* variables for the fields of id that are set, so
+// git falls back to its own configuration for the rest
+func identityEnv(role string, id *Identity) []string {
+	if id == nil {
+		return nil
+	}
+	var env []string
+	for _, field := range []struct{ name, value string }{
+		{"NAME", id.Name}, {"EMAIL", id.Email}, {"DATE", id.Date},
+	} {
+		if field.value != "" {
+			env = append(env, fmt.Sprintf("GIT_%s_%s=%s", role, field.name, field.value))
+		}
+	}
+	return env
+}
+
+// SignatureStatus returns git's one-letter verdict on the signature of a commit: "G" good,
+// "U" good with unknown validity, "E" can't be checked, "N" no signature, and "B", "X",
+// "Y" or "R" for bad, expired, made by an expired key or made by a revoked key
+func (r *Repo) SignatureStatus(ref string) (string, error) {
+	status, err := r.Output("log", "-1", "--format=%G?", ref)
+	if err != nil || status != "N" {
+		return status, err
+	}
+
+	// Git reports SSH signatures it has no allowed signers file for as missing, so look
+	// for the signature header itself
+	object, err := r.Output("cat-file", "commit", ref)
+	if err != nil {
+		return "", err
+	}
+	headers, _, _ := strings.Cut(object, "\n\n")
+	for _, line := range strings.Split(headers, "\n") {
+		if strings.HasPrefix(line, "gpgsig ") || strings.HasPrefix(line, "gpgsig-sha256 ") {
+			return "E", nil
+		}
+	}
+	return status, nil
+}
+
+// UpdateRef points ref at newValue. If oldValue is set the update only happens while ref
+// still points there; an all-zero oldValue requires that ref doesn't exist yet.
+func (r *Repo) UpdateRef(ref, newValue, oldValue string) error {
+	args := []string{"update-ref", ref, newValue}
+	if oldValue != "" {
+		args = append(args, oldValue)
+	}
+	_, err := r.Output(args...)
+	return err
+}
+
+// DeleteRef deletes a ref
+func (r *Repo) DeleteRef(ref string) error {
+	_, err := r.Output("update-ref", "-d", ref)
+	return err
+}
+
+// GetCommitAuthor returns the author of a commit
+func (r *Repo) GetCommitAuthor(ref string) (Identity, error) {
+	output, err := r.Output("log", "-1", "--format=%an%x00%ae%x00%ad", "--date=raw", ref)
+	if err != nil {
+		return Identity{}, err
+	}
+	parts := strings.SplitN(output, "\x00", 3)
+	if len(parts) != 3 {
+		return Identity{}, fmt.Errorf("unexpected author format for %s", ref)
+	}
+	return Identity{Name: parts[0], Email: parts[1], Date: parts[2]}, nil
+}
+
+// MakeTree writes a flat tree of regular files, given as name to blob hash, and returns its hash
+func (r *Repo) MakeTree(blobs map[string]string) (string, error) {
+	var entries strings.Builder
+	for name, blob := range blobs {
+		fmt.Fprintf(&entries, "100644 blob %s\t%s\n", blob, name)
+	}
+	return r.OutputEnv(nil, []byte(entries.String()), "mktree")
+}
+
+// ReadFileAt returns the content of a file as of a commit
+func (r *Repo) ReadFileAt(ref, path string) ([]byte, error) {
+	return r.outputBytes(nil, nil, "cat-file", "blob", fmt.Sprintf("%s:%s", ref, path))
+}
+
+// ListNotes returns the notes in a notes commit as annotated object to note blob.
+// The tree is read directly, so ref doesn't need to live under refs/notes/.
+func (r *Repo) ListNotes(ref string) (map[string]string, error) {
+	output, err := r.Output("ls-tree", "-r", ref)
+	if err != nil {
+		return nil, err
+	}
+	notes := make(map[string]string)
+	if output == "" {
+		return notes, nil
+	}
+	for _, line := range strings.Split(output, "\n") {
+		// <mode> blob <note blob>\t<annotated object, possibly split into fanout directories>
+		meta, path, ok := strings.Cut(line, "\t")
+		fields := strings.Fields(meta)
+		if !ok || len(fields) != 3 || fields[1] != "blob" {
+			continue
+		}
+		notes[strings.ReplaceAll(path, "/", "")] = fields[2]
+	}
+	return notes, nil
+}
+
+// HashObject writes content to the object database as a blob and returns its hash
+func (r *Repo) HashObject(content []byte) (string, error) {
+	return r.OutputEnv(nil, content, "hash-object", "-w", "--stdin")
+}
+
+// ReadBlob returns the content of a blob
+func (r *Repo) ReadBlob(blob string) ([]byte, error) {
+	return r.outputBytes(nil, nil, "cat-file", "blob", blob)
+}
+
+// MergeFile runs a three-way merge of a conflict's contents. favor may be "ours" or "theirs"
+// to resolve conflicting hunks automatically; otherwise conflict markers are left in place
+// and conflicted is true.
+func (r *Repo) MergeFile(c Conflict, favor string) (content []byte, conflicted bool, err error) {
+	dir, err := os.MkdirTemp("", "mob-merge-")
+	if err != nil {
+		return nil, false, err
+	}
+	defer os.RemoveAll(dir)
+
+	paths := make([]string, 3)
+	for i, blob := range []string{c.Ours, c.Base, c.Theirs} {
+		var data []byte
+		if blob != "" {
+			if data, err = r.ReadBlob(blob); err != nil {
+				return nil, false, err
+			}
+		}
+		paths[i] = filepath.Join(dir, strconv.Itoa(i))
+		if err := os.WriteFile(paths[i], data, 0644); err != nil {
+			return nil, false, err
+		}
+	}
+
+	args := []string{"merge-file", "-p", "-L", "pr", "-L", "base", "-L", "wip"}
+	if favor != "" {
+		args = append(args, "--"+favor)
+	}
+	args = append(args, paths...)
+
+	// merge-file exits with the number of conflicts, so a non-zero status isn't a failure
+	output, err := r.outputBytes(nil, nil, args...)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 && exitErr.ExitCode() < 128 {
+		return output, true, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return output, false, nil
+}
+
+// NewIndex creates a temporary index populated from a tree-ish
+func (r *Repo) NewIndex(treeish string) (*Index, error) {
+	return r.newIndex(treeish)
+}
+
+// NewEmptyIndex creates a temporary index with no entries
+func (r *Repo) NewEmptyIndex() (*Index, error) {
+	return r.newIndex("--empty")
+}
+
+// newIndex creates a temporary index and runs read-tree on it with args
+func (r *Repo) newIndex(args ...string) (*Index, error) {
+	file, err := os.CreateTemp("", "mob-index-")
+	if err != nil {
+		return nil, err
+	}
+	file.Close()
+	// read-tree refuses to read an empty file as an index
+	os.Remove(file.Name())
+
+	idx := &Index{repo: r, path: file.Name()}
+	if _, err := idx.output(nil, append([]string{"read-tree"}, args...)...); err != nil {
+		idx.Remove()
+		return nil, err
+	}
+	return idx, nil
+}
+
+// Remove deletes the temporary index
+func (i *Index) Remove() error {
+	if err := os.Remove(i.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// output runs a git command against the temporary index
+func (i *Index) output(stdin []byte, args ...string) (string, error) {
+	return i.repo.OutputEnv([]string{"GIT_INDEX_FILE=" + i.path}, stdin, args...)
+}
+
+// ApplyCommit applies the changes between from and to onto the index with a three-way
+// fallback. Conflicting paths are left unmerged; check them with Conflicts.
+func (i *Index) ApplyCommit(from, to string) error {
+	args := append(append([]string{"diff"}, diffFormat...), "--binary", "--full-index", from, to)
+	patch, err := i.repo.outputBytes(nil, nil, args...)
+	if err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(patch)) == 0 {
+		return nil
+	}
+	_, err = i.output(patch, "apply", "--cached", "--3way", "--whitespace=nowarn")
+	return err
+}
+
+// Conflicts returns the unmerged paths in the index
+func (i *Index) Conflicts() ([]Conflict, error) {
+	output, err := i.output(nil, "ls-files", "--unmerged")
+	if err != nil {
+		return nil, err
+	}
+	if output == "" {
+		return []Conflict{}, nil
+	}
+
+	var conflicts []Conflict
+	byPath := make(map[string]int)
+	for _, line := range strings.Split(output, "\n") {
+		// <mode> <object> <stage>\t<path>
+		meta, path, ok := strings.Cut(line, "\t")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(meta)
+		if len(fields) != 3 {
+			continue
+		}
+
+		idx, seen := byPath[path]
+		if !seen {
+			conflicts = append(conflicts, Conflict{Path: path})
+			idx = len(conflicts) - 1
+			byPath[path] = idx
+		}
+
+		c := &conflicts[idx]
+		switch fields[2] {
+		case "1":
+			c.Base = fields[1]
+		case "2":
+			c.Ours, c.OursMode = fields[1], fields[0]
+		case "3":
+			c.Theirs, c.TheirsMode = fields[1], fields[0]
+		}
+	}
+	return conflicts, nil
+}
+
+// Resolve replaces all stages of path with a single blob. An empty blob removes the path.
+func (i *Index) Resolve(path, mode, blob string) error {
+	if _, err := i.output(nil, "update-index", "--force-remove", "--", path); err != nil {
+		return err
+	}
+	if blob == "" {
+		return nil
+	}
+	_, err := i.output(nil, "update-index", "--add", "--cacheinfo", fmt.Sprintf("%s,%s,%s", mode, blob, path))
+	return err
+}
+
+// WriteTree writes the index as a tree and returns its hash
+func (i *Index) WriteTree() (string, error) {
+	return i.output(nil, "write-tree")
+}
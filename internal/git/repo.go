@@ -0,0 +1,231 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Errors a failed git command is classified as. Check them with errors.Is; the *Error
+// returned by Repo methods carries git's stderr.
+var (
+	ErrNotARepository = errors.New("not a git repository")
+	ErrMergeConflict  = errors.New("merge conflict")
+	ErrRefNotFound    = errors.New("ref not found")
+	ErrNonFastForward = errors.New("non-fast-forward update rejected")
+)
+
+// errorPatterns maps text git writes to stderr to the error it means
+var errorPatterns = []struct {
+	text string
+	kind error
+}{
+	{"not a git repository", ErrNotARepository},
+	{"CONFLICT", ErrMergeConflict},
+	{"could not apply", ErrMergeConflict},
+	{"Merge conflict", ErrMergeConflict},
+	{"needs merge", ErrMergeConflict},
+	{"patch does not apply", ErrMergeConflict},
+	{"non-fast-forward", ErrNonFastForward},
+	{"[rejected]", ErrNonFastForward},
+	{"stale info", ErrNonFastForward},
+	{"but expected", ErrNonFastForward},
+	{"unknown revision", ErrRefNotFound},
+	{"bad revision", ErrRefNotFound},
+	{"Needed a single revision", ErrRefNotFound},
+	{"not a valid object name", ErrRefNotFound},
+	{"Not a valid object name", ErrRefNotFound},
+	{"not a valid ref", ErrRefNotFound},
+	{"couldn't find remote ref", ErrRefNotFound},
+	{"did not match any", ErrRefNotFound},
+	{"invalid reference", ErrRefNotFound},
+}
+
+// Error is a git command that failed
+type Error struct {
+	Args   []string
+	Stderr string
+	// Err is the underlying error, usually an *exec.ExitError
+	Err error
+	// Kind is one of the Err* values, or nil if the failure wasn't recognized
+	Kind error
+}
+
+func (e *Error) Error() string {
+	command := "git"
+	if len(e.Args) > 0 {
+		command += " " + e.Args[0]
+	}
+	if msg := e.message(); msg != "" {
+		return fmt.Sprintf("%s: %s", command, msg)
+	}
+	return fmt.Sprintf("%s: %v", command, e.Err)
+}
+
+// message picks the line of stderr that explains the failure
+func (e *Error) message() string {
+	lines := strings.Split(strings.TrimSpace(e.Stderr), "\n")
+	for _, line := range lines {
+		if strings.HasPrefix(line, "fatal: ") || strings.HasPrefix(line, "error: ") {
+			return strings.TrimSpace(line)
+		}
+	}
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+func (e *Error) Unwrap() []error {
+	if e.Kind == nil {
+		return []error{e.Err}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// ExitCode returns the exit status of the command, or -1 if it didn't exit normally
+func (e *Error) ExitCode() int {
+	var exitErr *exec.ExitError
+	if errors.As(e.Err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// newError classifies a failed command by what it wrote to stderr
+func newError(args []string, stderr string, err error) *Error {
+	e := &Error{Args: args, Stderr: stderr, Err: err}
+	for _, p := range errorPatterns {
+		if strings.Contains(stderr, p.text) {
+			e.Kind = p.kind
+			break
+		}
+	}
+	return e
+}
+
+// Repo runs git commands in one repository
+type Repo struct {
+	// Dir is the directory commands run in; empty means the current directory
+	Dir string
+	// Env holds extra environment variables for every command
+	Env []string
+	ctx context.Context
+}
+
+// Open returns the repository containing dir, rooted at its top-level directory
+func Open(ctx context.Context, dir string) (*Repo, error) {
+	r := &Repo{Dir: dir, ctx: ctx}
+	root, err := r.Output("rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	r.Dir = root
+	return r, nil
+}
+
+// defaultRepo is used by packages that aren't handed a Repo
+var defaultRepo = &Repo{}
+
+// SetDefault makes r the repository returned by Default
+func SetDefault(r *Repo) {
+	defaultRepo = r
+}
+
+// Default returns the repository set with SetDefault, or one in the current directory
+func Default() *Repo {
+	return defaultRepo
+}
+
+// WithContext returns a copy of r whose commands are killed when ctx is done
+func (r *Repo) WithContext(ctx context.Context) *Repo {
+	copy := *r
+	copy.ctx = ctx
+	return &copy
+}
+
+// WithoutCancel returns a copy of r whose commands keep running after its context is
+// cancelled, for cleanup that has to finish
+func (r *Repo) WithoutCancel() *Repo {
+	return r.WithContext(context.WithoutCancel(r.Context()))
+}
+
+// Context returns the context commands run with
+func (r *Repo) Context() context.Context {
+	if r.ctx == nil {
+		return context.Background()
+	}
+	return r.ctx
+}
+
+// Root returns the top-level directory of the working tree
+func (r *Repo) Root() (string, error) {
+	if r.Dir != "" {
+		return r.Dir, nil
+	}
+	return r.Output("rev-parse", "--show-toplevel")
+}
+
+// command prepares a git command. Stderr is captured into stderr and, if tee is set,
+// also written to the terminal.
+func (r *Repo) command(env []string, stdin []byte, stderr *bytes.Buffer, tee bool, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(r.Context(), "git", args...)
+	cmd.Dir = r.Dir
+	if len(r.Env) > 0 || len(env) > 0 {
+		cmd.Env = append(append(os.Environ(), r.Env...), env...)
+	}
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	cmd.Stderr = stderr
+	if tee {
+		cmd.Stderr = io.MultiWriter(os.Stderr, stderr)
+	}
+	return cmd
+}
+
+// Run executes a git command with stdout and stderr connected to the terminal
+func (r *Repo) Run(args ...string) error {
+	var stderr bytes.Buffer
+	cmd := r.command(nil, nil, &stderr, true, args...)
+	cmd.Stdout = os.Stdout
+	if err := cmd.Run(); err != nil {
+		return newError(args, stderr.String(), err)
+	}
+	return nil
+}
+
+// Output executes a git command and returns the output
+func (r *Repo) Output(args ...string) (string, error) {
+	return r.OutputEnv(nil, nil, args...)
+}
+
+// OutputEnv executes a git command with extra environment variables and optional stdin and returns the output
+func (r *Repo) OutputEnv(env []string, stdin []byte, args ...string) (string, error) {
+	output, err := r.outputBytes(env, stdin, args...)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// outputBytes executes a git command and returns its output untouched
+func (r *Repo) outputBytes(env []string, stdin []byte, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	output, err := r.command(env, stdin, &stderr, false, args...).Output()
+	if err != nil {
+		return output, newError(args, stderr.String(), err)
+	}
+	return output, nil
+}
+
+// path resolves a path git printed relative to the directory it ran in
+func (r *Repo) path(path string) string {
+	if filepath.IsAbs(path) || r.Dir == "" {
+		return path
+	}
+	return filepath.Join(r.Dir, path)
+}
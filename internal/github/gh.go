@@ -0,0 +1,16 @@
+package github
+
+import "github.com/joaosaffran/mob/internal/shell"
+
+// repoDir is the repository gh runs in, so it picks the GitHub repo from that one's remotes
+var repoDir string
+
+// SetRepoDir makes gh run in dir instead of the current directory
+func SetRepoDir(dir string) {
+	repoDir = dir
+}
+
+// gh runs the GitHub CLI and returns its output
+func gh(args ...string) ([]byte, error) {
+	return shell.OutputIn(repoDir, "gh", args...)
+}
@@ -3,8 +3,7 @@ package github
 import (
 	"encoding/json"
 	"fmt"
-
-	"github.com/joaosaffran/mob/internal/shell"
+	"strconv"
 )
 
 // Issue represents a GitHub issue
@@ -15,7 +14,7 @@ type Issue struct {
 
 // GetAssignedIssues fetches issues assigned to the current user using gh CLI
 func GetAssignedIssues() ([]Issue, error) {
-	output, err := shell.Output("gh", "issue", "list", "--assignee", "joaosaffran", "--json", "number,title")
+	output, err := gh("issue", "list", "--assignee", "joaosaffran", "--json", "number,title")
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch issues: %w", err)
 	}
@@ -27,3 +26,18 @@ func GetAssignedIssues() ([]Issue, error) {
 
 	return issues, nil
 }
+
+// GetIssue fetches a single issue by number using gh CLI
+func GetIssue(number int) (*Issue, error) {
+	output, err := gh("issue", "view", strconv.Itoa(number), "--json", "number,title")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch issue #%d: %w", number, err)
+	}
+
+	var issue Issue
+	if err := json.Unmarshal(output, &issue); err != nil {
+		return nil, fmt.Errorf("failed to parse issue: %w", err)
+	}
+
+	return &issue, nil
+}
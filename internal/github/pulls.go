@@ -0,0 +1,109 @@
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const pullRequestFields = "number,title,url,state,baseRefName,headRefName,mergeCommit,mergedAt"
+
+// PullRequest represents a GitHub pull request
+type PullRequest struct {
+	Number      int       `json:"number"`
+	Title       string    `json:"title"`
+	URL         string    `json:"url"`
+	State       string    `json:"state"`
+	BaseRefName string    `json:"baseRefName"`
+	HeadRefName string    `json:"headRefName"`
+	MergeCommit *Commit   `json:"mergeCommit"`
+	MergedAt    time.Time `json:"mergedAt"`
+}
+
+// Commit identifies a commit in a GitHub response
+type Commit struct {
+	OID string `json:"oid"`
+}
+
+// FindPullRequest returns the open pull request for a head branch, or nil if there is none
+func FindPullRequest(head string) (*PullRequest, error) {
+	output, err := gh("pr", "list", "--head", head, "--state", "open", "--json", pullRequestFields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pull requests: %w", err)
+	}
+
+	var prs []PullRequest
+	if err := json.Unmarshal(output, &prs); err != nil {
+		return nil, fmt.Errorf("failed to parse pull requests: %w", err)
+	}
+
+	if len(prs) == 0 {
+		return nil, nil
+	}
+	return &prs[0], nil
+}
+
+// GetPullRequest fetches a pull request by number
+func GetPullRequest(number int) (*PullRequest, error) {
+	output, err := gh("pr", "view", strconv.Itoa(number), "--json", pullRequestFields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch pull request #%d: %w", number, err)
+	}
+
+	var pr PullRequest
+	if err := json.Unmarshal(output, &pr); err != nil {
+		return nil, fmt.Errorf("failed to parse pull request: %w", err)
+	}
+
+	return &pr, nil
+}
+
+// GetPullRequestForBranch fetches the most recent pull request for a head branch, in any state
+func GetPullRequestForBranch(head string) (*PullRequest, error) {
+	output, err := gh("pr", "view", head, "--json", pullRequestFields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch pull request for '%s': %w", head, err)
+	}
+
+	var pr PullRequest
+	if err := json.Unmarshal(output, &pr); err != nil {
+		return nil, fmt.Errorf("failed to parse pull request: %w", err)
+	}
+
+	return &pr, nil
+}
+
+// CreatePullRequest opens a new pull request from head into base
+func CreatePullRequest(base, head, title, body string) (*PullRequest, error) {
+	if _, err := gh("pr", "create", "--base", base, "--head", head, "--title", title, "--body", body); err != nil {
+		return nil, fmt.Errorf("failed to create pull request: %w", err)
+	}
+
+	pr, err := FindPullRequest(head)
+	if err != nil {
+		return nil, err
+	}
+	if pr == nil {
+		return nil, fmt.Errorf("pull request for '%s' was not found after creation", head)
+	}
+	return pr, nil
+}
+
+// EditPullRequest updates the base, title and body of an existing pull request
+func EditPullRequest(number int, base, title, body string) error {
+	if _, err := gh("pr", "edit", strconv.Itoa(number), "--base", base, "--title", title, "--body", body); err != nil {
+		return fmt.Errorf("failed to edit pull request #%d: %w", number, err)
+	}
+	return nil
+}
+
+// GetDefaultBranch returns the default branch of the current repository
+func GetDefaultBranch() (string, error) {
+	output, err := gh("repo", "view", "--json", "defaultBranchRef", "--jq", ".defaultBranchRef.name")
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch default branch: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
@@ -0,0 +1,275 @@
+package journal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/joaosaffran/mob/internal/git"
+	"github.com/joaosaffran/mob/internal/tracking"
+)
+
+const journalDir = ".mob"
+const journalFile = "journal.json"
+
+// Journal records an in-progress mob operation so it can be finished or undone after a crash
+type Journal struct {
+	Operation      string                 `json:"operation"`
+	Args           []string               `json:"args"`
+	Issue          string                 `json:"issue"`
+	OriginalBranch string                 `json:"original_branch"`
+	Refs           map[string]string      `json:"refs"`
+	Tracking       *tracking.TrackingData `json:"tracking"`
+	Step           string                 `json:"step"`
+	Push           *PushTarget            `json:"push,omitempty"`
+	StartedAt      time.Time              `json:"started_at"`
+	UpdatedAt      time.Time              `json:"updated_at"`
+}
+
+// PushTarget records the push an operation was performing
+type PushTarget struct {
+	Remote string `json:"remote"`
+	Branch string `json:"branch"`
+	Force  bool   `json:"force"`
+}
+
+// getJournalPath returns the path to the journal file at the root of the repository
+func getJournalPath() (string, error) {
+	root, err := git.Default().Root()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, journalDir, journalFile), nil
+}
+
+// Load returns the journal of an interrupted operation, or nil if there is none
+func Load() (*Journal, error) {
+	path, err := getJournalPath()
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var j Journal
+	if err := json.Unmarshal(file, &j); err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+// Begin starts a journal for an operation, snapshotting the current branch and tracking data.
+// It fails if an interrupted operation still needs to be recovered.
+func Begin(operation, issue string, args []string) (*Journal, error) {
+	existing, err := Load()
+	if err != nil {
+		return nil, fmt.Errorf("error reading journal: %w", err)
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("an interrupted '%s' was found. Run 'mob recover' first", existing.Operation)
+	}
+
+	currentBranch, err := git.Default().CurrentBranch()
+	if err != nil {
+		return nil, fmt.Errorf("error getting current branch: %w", err)
+	}
+
+	trackingData, err := tracking.Load()
+	if err != nil {
+		return nil, fmt.Errorf("error loading tracking data: %w", err)
+	}
+
+	now := time.Now()
+	j := &Journal{
+		Operation:      operation,
+		Args:           args,
+		Issue:          issue,
+		OriginalBranch: currentBranch,
+		Refs:           make(map[string]string),
+		Tracking:       trackingData,
+		Step:           "start",
+		StartedAt:      now,
+		UpdatedAt:      now,
+	}
+	return j, j.save()
+}
+
+// Record remembers the current commit of a branch so it can be restored.
+// Branches that don't exist yet are recorded with an empty commit and deleted on undo.
+func (j *Journal) Record(branch string) error {
+	if _, ok := j.Refs[branch]; ok {
+		return nil
+	}
+	repo := git.Default()
+
+	commit := ""
+	if repo.BranchExists(branch) {
+		hash, err := repo.GetCommitHash(branch)
+		if err != nil {
+			return fmt.Errorf("error getting commit hash: %w", err)
+		}
+		commit = hash
+	}
+	j.Refs[branch] = commit
+	return j.save()
+}
+
+// SetStep records the step the operation is about to perform
+func (j *Journal) SetStep(step string) error {
+	j.Step = step
+	return j.save()
+}
+
+// SetPush records the step that pushes a branch, so recovery can finish it
+func (j *Journal) SetPush(remote, branch string, force bool) error {
+	j.Push = &PushTarget{Remote: remote, Branch: branch, Force: force}
+	return j.SetStep("push")
+}
+
+// Finish removes the journal once the operation completed or was rolled back
+func (j *Journal) Finish() error {
+	path, err := getJournalPath()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// save writes the journal to disk. It goes to a temporary file that is renamed over the
+// journal, so a crash mid-write never leaves half a journal that blocks every operation.
+func (j *Journal) save() error {
+	path, err := getJournalPath()
+	if err != nil {
+		return err
+	}
+
+	// Create directory if it doesn't exist
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	j.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, journalFile+".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Undo aborts any half-finished git operation and restores the recorded branches and tracking data
+func (j *Journal) Undo() error {
+	// Undo has to run to the end even when an interrupt cancelled the operation. Tracking
+	// data is restored through the default repo, so that one stops being cancellable too.
+	repo := git.Default().WithoutCancel()
+	git.SetDefault(repo)
+
+	if repo.OperationInProgress("rebase-merge") || repo.OperationInProgress("rebase-apply") {
+		repo.AbortRebase()
+	}
+	if repo.OperationInProgress("CHERRY_PICK_HEAD") {
+		repo.AbortCherryPick()
+	}
+	if repo.OperationInProgress("MERGE_HEAD") {
+		repo.AbortMerge()
+	}
+
+	currentBranch, err := repo.CurrentBranch()
+	if err != nil {
+		return fmt.Errorf("error getting current branch: %w", err)
+	}
+	if currentBranch != j.OriginalBranch {
+		// Squash merges leave no MERGE_HEAD, so discard leftovers on mob's own pr branch
+		if strings.HasPrefix(currentBranch, "pr/") {
+			repo.ResetHard("HEAD")
+		}
+		if err := repo.Checkout(j.OriginalBranch); err != nil {
+			return fmt.Errorf("error checking out '%s': %w", j.OriginalBranch, err)
+		}
+	}
+
+	for branch, commit := range j.Refs {
+		if commit == "" {
+			// The operation created this branch
+			if repo.BranchExists(branch) && branch != j.OriginalBranch {
+				if err := repo.DeleteBranch(branch); err != nil {
+					return fmt.Errorf("error deleting '%s': %w", branch, err)
+				}
+			}
+			continue
+		}
+
+		current, err := repo.GetCommitHash(branch)
+		if err == nil && current == commit {
+			continue
+		}
+		if branch == j.OriginalBranch {
+			// A hard reset would throw away uncommitted work along with the operation's commits
+			dirty, statusErr := repo.HasUncommittedChanges()
+			if statusErr != nil {
+				return fmt.Errorf("error checking for uncommitted changes: %w", statusErr)
+			}
+			if dirty {
+				return fmt.Errorf("'%s' has uncommitted changes; commit or stash them, then undo again", branch)
+			}
+			err = repo.ResetHard(commit)
+		} else {
+			err = repo.SetBranch(branch, commit)
+		}
+		if err != nil {
+			return fmt.Errorf("error restoring '%s' to %s: %w", branch, commit, err)
+		}
+	}
+
+	if err := j.RestoreTracking(); err != nil {
+		return fmt.Errorf("error restoring tracking data: %w", err)
+	}
+
+	return j.Finish()
+}
+
+// RestoreTracking puts the issue's tracking back the way it was when the operation
+// started. Other issues are left alone, so changes other mob processes made to them
+// in the meantime are kept.
+func (j *Journal) RestoreTracking() error {
+	if j.Tracking == nil {
+		return nil
+	}
+	return tracking.Update(func(trackingData *tracking.TrackingData) error {
+		trackingData.RestoreIssue(j.Issue, j.Tracking)
+		return nil
+	})
+}
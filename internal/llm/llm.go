@@ -3,6 +3,9 @@ package llm
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
+
+	"github.com/joaosaffran/mob/internal/diff"
 )
 
 // Recommendation represents a code improvement suggestion
@@ -12,25 +15,97 @@ type Recommendation struct {
 	Severity    string `json:"severity"` // "high", "medium", "low"
 }
 
+// maxDiffLen caps the size of the diff sent to the LLM
+const maxDiffLen = 8000
+
+// promptDiff renders the changes for the prompt within limit bytes. Files are kept whole
+// while they fit and the others are listed by name, so the model knows what it didn't see.
+func promptDiff(files []*diff.File, limit int) string {
+	var sb strings.Builder
+	var omitted, special []string
+	for _, f := range files {
+		// Pointer files, submodule commits and binary patches aren't code to review
+		if f.IsSpecial() {
+			special = append(special, fmt.Sprintf("%s: %s", f.Label(), f.Summary()))
+			continue
+		}
+		text := f.String()
+		if f.Source != "" {
+			// Tell the model which changes aren't committed yet
+			text = fmt.Sprintf("# %s changes\n%s", f.Source, text)
+		}
+		if sb.Len()+len(text) <= limit {
+			sb.WriteString(text)
+			continue
+		}
+		if sb.Len() == 0 {
+			// A single large file still gets reviewed as far as it fits
+			sb.WriteString(truncateFile(f, limit))
+			continue
+		}
+		added, removed := f.Counts()
+		omitted = append(omitted, fmt.Sprintf("%s (+%d -%d)", f.Label(), added, removed))
+	}
+
+	if len(special) > 0 {
+		sb.WriteString("\nThese files also changed but aren't text, so only a summary is given:\n")
+		for _, line := range special {
+			sb.WriteString("- " + line + "\n")
+		}
+	}
+	if len(omitted) > 0 {
+		sb.WriteString("\nThese files also changed but were left out to keep the diff short:\n")
+		for _, name := range omitted {
+			sb.WriteString("- " + name + "\n")
+		}
+	}
+	return sb.String()
+}
+
+// truncateFile renders the start of a file's diff, cut at a line boundary within limit bytes
+func truncateFile(f *diff.File, limit int) string {
+	const marker = "... (truncated)\n"
+	var sb strings.Builder
+	write := func(line string) bool {
+		if sb.Len()+len(line)+1+len(marker) > limit {
+			sb.WriteString(marker)
+			return false
+		}
+		sb.WriteString(line + "\n")
+		return true
+	}
+
+	for _, line := range f.Header {
+		if !write(line) {
+			return sb.String()
+		}
+	}
+	for _, h := range f.Hunks {
+		if !write(h.Header()) {
+			return sb.String()
+		}
+		for _, l := range h.Lines {
+			if !write(l.Kind.Prefix() + l.Content) {
+				return sb.String()
+			}
+		}
+	}
+	return sb.String()
+}
+
 // GetRecommendations fetches code review recommendations from an LLM
-func GetRecommendations(diff string) ([]Recommendation, error) {
+func GetRecommendations(files []*diff.File) ([]Recommendation, error) {
 	if !IsAPIKeySet() {
 		return getDefaultRecommendations(), nil
 	}
 
-	// Truncate diff if too long
-	maxDiffLen := 8000
-	if len(diff) > maxDiffLen {
-		diff = diff[:maxDiffLen] + "\n... (truncated)"
-	}
-
 	// Load prompts from templates
 	systemPrompt, err := GetSystemPrompt()
 	if err != nil {
 		return nil, fmt.Errorf("failed to load system prompt: %w", err)
 	}
 
-	userPrompt, err := GetUserPrompt(diff)
+	userPrompt, err := GetUserPrompt(promptDiff(files, maxDiffLen))
 	if err != nil {
 		return nil, fmt.Errorf("failed to load user prompt: %w", err)
 	}
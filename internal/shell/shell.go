@@ -1,20 +1,10 @@
 package shell
 
-import (
-	"os"
-	"os/exec"
-)
+import "os/exec"
 
-// Run executes a command with stdout and stderr connected to the terminal
-func Run(name string, args ...string) error {
-	cmd := exec.Command(name, args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
-}
-
-// Output executes a command and returns its output
-func Output(name string, args ...string) ([]byte, error) {
+// OutputIn executes a command in dir and returns its output. An empty dir means the current directory.
+func OutputIn(dir string, name string, args ...string) ([]byte, error) {
 	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
 	return cmd.Output()
 }
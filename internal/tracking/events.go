@@ -0,0 +1,116 @@
+package tracking
+
+import (
+	"sort"
+	"time"
+)
+
+// Event types recorded for an issue
+const (
+	// EventCreated is recorded when mob init creates the wip branch
+	EventCreated = "created"
+	// EventReview is recorded at the end of a review session, with its duration
+	EventReview = "review"
+	// EventUpdate is recorded when mob update carries commits onto the pr branch
+	EventUpdate = "update"
+	// EventPause marks the start of a break
+	EventPause = "pause"
+	// EventResume marks the end of a break
+	EventResume = "resume"
+	// EventFinish is recorded when mob finish archives the issue
+	EventFinish = "finish"
+)
+
+// MaxIdleGap is the longest an event keeps counting as work on its issue when nothing
+// else happens
+const MaxIdleGap = 4 * time.Hour
+
+// Event is something that happened to an issue
+type Event struct {
+	Type            string    `json:"type"`
+	At              time.Time `json:"at"`
+	DurationSeconds int64     `json:"duration_seconds,omitempty"`
+}
+
+// Duration returns how long the event lasted, for events that span time like reviews
+func (e Event) Duration() time.Duration {
+	return time.Duration(e.DurationSeconds) * time.Second
+}
+
+// AddEvent records an event for an issue
+func (t *TrackingData) AddEvent(issue string, event Event) {
+	tracking := t.GetIssueTracking(issue)
+	tracking.Events = append(tracking.Events, event)
+	t.Issues[issue] = tracking
+}
+
+// IsPaused reports whether the latest event of an issue is a pause. Any other event,
+// like an update or a review, means work resumed.
+func (i IssueTracking) IsPaused() bool {
+	return len(i.Events) > 0 && i.Events[len(i.Events)-1].Type == EventPause
+}
+
+// ActiveTime returns how long an issue was worked on between since and until. Each event
+// on the issue, other than a pause, starts a stretch of work that lasts until the issue's
+// next event. A stretch ends early when another issue becomes active (others are the times
+// that happened, sorted) and lasts at most MaxIdleGap, or as long as the review it records,
+// so nights and weekends without a pause aren't counted. Nothing counts after the finish.
+// A zero since means from the start.
+func (i IssueTracking) ActiveTime(since, until time.Time, others []time.Time) time.Duration {
+	var total time.Duration
+	for n, e := range i.Events {
+		if e.Type == EventFinish {
+			break
+		}
+		if e.Type == EventPause {
+			continue
+		}
+
+		end := e.At.Add(max(MaxIdleGap, e.Duration()))
+		if n+1 < len(i.Events) && i.Events[n+1].At.Before(end) {
+			end = i.Events[n+1].At
+		}
+		// Working on another issue ends the stretch
+		next := sort.Search(len(others), func(k int) bool { return others[k].After(e.At) })
+		if next < len(others) && others[next].Before(end) {
+			end = others[next]
+		}
+
+		start := e.At
+		if start.Before(since) {
+			start = since
+		}
+		if end.After(until) {
+			end = until
+		}
+		if end.After(start) {
+			total += end.Sub(start)
+		}
+	}
+	return total
+}
+
+// ActivityTimes returns when issues other than except became active, sorted: every event
+// of an active or finished issue except pauses and finishes
+func (t *TrackingData) ActivityTimes(except string) []time.Time {
+	var times []time.Time
+	add := func(events []Event) {
+		for _, e := range events {
+			if e.Type != EventPause && e.Type != EventFinish {
+				times = append(times, e.At)
+			}
+		}
+	}
+	for issue, tracking := range t.Issues {
+		if issue != except {
+			add(tracking.Events)
+		}
+	}
+	for issue, archived := range t.Archive {
+		if issue != except {
+			add(archived.Events)
+		}
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	return times
+}
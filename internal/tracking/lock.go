@@ -0,0 +1,57 @@
+package tracking
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const lockFileName = "tracking.lock"
+
+// locker is implemented by stores that live in the repository and are shared between processes
+type locker interface {
+	locked() bool
+}
+
+// withLock runs fn with the current store. For stores in the repository it holds an
+// exclusive advisory lock on .mob/tracking.lock, so concurrent mob processes don't
+// interleave reads and writes. The lock is per open file, so fn must not call anything
+// that takes it again.
+func withLock(fn func(store Store) error) error {
+	store, err := getStore()
+	if err != nil {
+		return err
+	}
+	if l, ok := store.(locker); !ok || !l.locked() {
+		return fn(store)
+	}
+
+	path, err := getTrackingPath()
+	if err != nil {
+		return err
+	}
+	path = filepath.Join(filepath.Dir(path), lockFileName)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	locked, err := tryLockFile(file)
+	if err != nil {
+		return fmt.Errorf("error locking tracking data: %w", err)
+	}
+	if !locked {
+		fmt.Fprintln(os.Stderr, "Waiting for another mob process to release the tracking data...")
+		if err := lockFile(file); err != nil {
+			return fmt.Errorf("error locking tracking data: %w", err)
+		}
+	}
+	defer unlockFile(file)
+
+	return fn(store)
+}
@@ -0,0 +1,57 @@
+package tracking
+
+import "sync"
+
+// MemoryStore keeps tracking data in memory, so code using tracking can run without
+// a repository. Load returns a copy; changes are only kept after Save.
+type MemoryStore struct {
+	mu   sync.Mutex
+	file []byte
+}
+
+// NewMemoryStore returns an empty in-memory Store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// Load returns a copy of the saved data
+func (m *MemoryStore) Load() (*TrackingData, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, _, err := parse(m.file)
+	return data, err
+}
+
+// Save replaces the saved data
+func (m *MemoryStore) Save(data *TrackingData) error {
+	file, err := data.marshal()
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.file = file
+	return nil
+}
+
+// SetForkPoint records an issue's fork point in the saved data
+func (m *MemoryStore) SetForkPoint(issue string, forkPoint string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, _, err := parse(m.file)
+	if err != nil {
+		return err
+	}
+	data.SetForkPoint(issue, forkPoint)
+	file, err := data.marshal()
+	if err != nil {
+		return err
+	}
+	m.file = file
+	return nil
+}
+
+// GetUnmergedCommits returns the commits of allCommits the saved data doesn't list as merged
+func (m *MemoryStore) GetUnmergedCommits(issue string, allCommits []string, patchIDs PatchIDFunc) ([]string, error) {
+	return getUnmergedCommits(m, issue, allCommits, patchIDs)
+}
@@ -0,0 +1,204 @@
+package tracking
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// SchemaVersion is the version of the tracking data written by this build.
+// It must equal len(migrations).
+const SchemaVersion = 5
+
+// migration upgrades raw tracking data by one schema version
+type migration struct {
+	description string
+	apply       func(raw map[string]any) error
+}
+
+// migrations[i] upgrades data from version i to version i+1. Files written before
+// schema_version existed are version 0. Migrations work on raw JSON so they keep
+// working after the structs change.
+var migrations = []migration{
+	{"record the history mode and merged commits of every issue explicitly", migrateV0},
+	{"add the archive of finished issues", addOptionalFields},
+	{"add patch IDs of merged commits", addOptionalFields},
+	{"add issue events", addOptionalFields},
+	{"add the patch ID of reviewed changes", addOptionalFields},
+}
+
+// migrateV0 fills in fields older versions left out. Issues without a mode were
+// updated one squash commit at a time, so they keep that mode if the default changes.
+func migrateV0(raw map[string]any) error {
+	issues, _ := raw["issues"].(map[string]any)
+	for issue, value := range issues {
+		entry, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("issue %s: expected an object", issue)
+		}
+		if mode, _ := entry["mode"].(string); mode == "" {
+			entry["mode"] = ModePerUpdate
+		}
+		if entry["merged_commits"] == nil {
+			entry["merged_commits"] = []any{}
+		}
+	}
+	return nil
+}
+
+// addOptionalFields changes nothing; the version only keeps older builds, which would drop
+// the new fields on save, from rewriting the data. Patch IDs are recorded from the next
+// update on; until then older merged commits are matched by hash only.
+func addOptionalFields(raw map[string]any) error {
+	return nil
+}
+
+// schemaVersionOf returns the schema version recorded in raw data
+func schemaVersionOf(raw map[string]any) (int, error) {
+	value, ok := raw["schema_version"]
+	if !ok {
+		return 0, nil
+	}
+	version, ok := value.(float64)
+	if !ok || version < 0 || version != float64(int(version)) {
+		return 0, fmt.Errorf("invalid schema_version %v", value)
+	}
+	return int(version), nil
+}
+
+// migrate upgrades serialized tracking data to SchemaVersion. It returns the upgraded
+// data and the version the input was written with.
+func migrate(file []byte) ([]byte, int, error) {
+	var raw map[string]any
+	if err := json.Unmarshal(file, &raw); err != nil {
+		return nil, 0, err
+	}
+	if raw == nil {
+		raw = make(map[string]any)
+	}
+
+	version, err := schemaVersionOf(raw)
+	if err != nil {
+		return nil, 0, err
+	}
+	if version > SchemaVersion {
+		return nil, version, fmt.Errorf("tracking data has schema version %d but this mob only supports up to %d; upgrade mob", version, SchemaVersion)
+	}
+	if version == SchemaVersion {
+		return file, version, nil
+	}
+
+	for v := version; v < SchemaVersion; v++ {
+		if err := migrations[v].apply(raw); err != nil {
+			return nil, version, fmt.Errorf("error migrating tracking data from version %d: %w", v, err)
+		}
+	}
+	raw["schema_version"] = SchemaVersion
+
+	upgraded, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return nil, version, err
+	}
+	return upgraded, version, nil
+}
+
+// backup keeps a copy of tracking data from before a migration next to the tracking file
+func backup(file []byte, version int) (string, error) {
+	path, err := getTrackingPath()
+	if err != nil {
+		return "", err
+	}
+	backupPath := filepath.Join(filepath.Dir(path), fmt.Sprintf("tracking.v%d.json.bak", version))
+	if err := os.MkdirAll(filepath.Dir(backupPath), 0755); err != nil {
+		return "", err
+	}
+	return backupPath, os.WriteFile(backupPath, file, 0644)
+}
+
+// CheckResult describes the stored tracking data
+type CheckResult struct {
+	Version        int
+	NeedsMigration bool
+	Problems       []string
+}
+
+// versioned is implemented by stores that can hold data written with an older schema
+type versioned interface {
+	// storedVersion returns the oldest schema version of the stored data
+	storedVersion() (int, error)
+	// validate returns the problems found in the stored data without changing it
+	validate() ([]string, error)
+}
+
+// checkDocument returns the problems found in one serialized tracking document
+func checkDocument(file []byte) []string {
+	data, _, err := parse(file)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	// Fields this build doesn't know about would be dropped on the next save
+	var problems []string
+	upgraded, _, _ := migrate(file)
+	decoder := json.NewDecoder(bytes.NewReader(upgraded))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&TrackingData{}); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	for issue, entry := range data.Issues {
+		if entry.ForkPoint == "" {
+			problems = append(problems, fmt.Sprintf("issue %s has no fork point", issue))
+		}
+		if entry.Mode != "" && !IsValidMode(entry.Mode) {
+			problems = append(problems, fmt.Sprintf("issue %s has unknown mode '%s'", issue, entry.Mode))
+		}
+	}
+	return problems
+}
+
+// Check validates the stored tracking data without changing it
+func Check() (*CheckResult, error) {
+	result := &CheckResult{Version: SchemaVersion}
+	err := withLock(func(store Store) error {
+		v, ok := store.(versioned)
+		if !ok {
+			return nil
+		}
+		version, err := v.storedVersion()
+		if err != nil {
+			// Unreadable data is a problem to report, not a failure to check
+			result.Problems = append(result.Problems, err.Error())
+			return nil
+		}
+		result.Version = version
+		result.NeedsMigration = version < SchemaVersion
+		result.Problems, err = v.validate()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Migrate upgrades the stored tracking data to SchemaVersion and returns the version
+// the data had before. Loading the data migrates it too; this only makes it explicit.
+func Migrate() (int, error) {
+	version := SchemaVersion
+	err := withLock(func(store Store) error {
+		v, ok := store.(versioned)
+		if !ok {
+			return nil
+		}
+		var err error
+		if version, err = v.storedVersion(); err != nil || version == SchemaVersion {
+			return err
+		}
+		_, err = store.Load()
+		return err
+	})
+	return version, err
+}
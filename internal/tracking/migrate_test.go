@@ -0,0 +1,159 @@
+package tracking
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMigrationsMatchSchemaVersion(t *testing.T) {
+	if len(migrations) != SchemaVersion {
+		t.Errorf("%d migrations for schema version %d", len(migrations), SchemaVersion)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	tests := []struct {
+		name        string
+		file        string
+		wantVersion int
+		wantErr     string
+		// check inspects the migrated document
+		check func(t *testing.T, raw map[string]any)
+	}{
+		{
+			name:        "version 0 gets a mode and merged commits",
+			file:        `{"issues": {"42": {"fork_point": "base"}}}`,
+			wantVersion: 0,
+			check: func(t *testing.T, raw map[string]any) {
+				entry := raw["issues"].(map[string]any)["42"].(map[string]any)
+				if entry["mode"] != ModePerUpdate {
+					t.Errorf("mode = %v, want %s", entry["mode"], ModePerUpdate)
+				}
+				if merged, ok := entry["merged_commits"].([]any); !ok || len(merged) != 0 {
+					t.Errorf("merged_commits = %v, want []", entry["merged_commits"])
+				}
+			},
+		},
+		{
+			name:        "version 0 keeps a recorded mode and merged commits",
+			file:        `{"issues": {"42": {"fork_point": "base", "mode": "preserve", "merged_commits": ["c1"]}}}`,
+			wantVersion: 0,
+			check: func(t *testing.T, raw map[string]any) {
+				entry := raw["issues"].(map[string]any)["42"].(map[string]any)
+				if entry["mode"] != ModePreserve {
+					t.Errorf("mode = %v, want %s", entry["mode"], ModePreserve)
+				}
+				if merged, _ := entry["merged_commits"].([]any); len(merged) != 1 || merged[0] != "c1" {
+					t.Errorf("merged_commits = %v, want [c1]", entry["merged_commits"])
+				}
+			},
+		},
+		{
+			name:        "empty document",
+			file:        `{}`,
+			wantVersion: 0,
+		},
+		{
+			name:        "intermediate version",
+			file:        `{"schema_version": 2, "issues": {"42": {"fork_point": "base", "mode": "squash-all"}}}`,
+			wantVersion: 2,
+			check: func(t *testing.T, raw map[string]any) {
+				entry := raw["issues"].(map[string]any)["42"].(map[string]any)
+				if entry["mode"] != ModeSquashAll {
+					t.Errorf("mode = %v, want %s", entry["mode"], ModeSquashAll)
+				}
+			},
+		},
+		{
+			name:        "current version",
+			file:        fmt.Sprintf(`{"schema_version": %d, "issues": {}}`, SchemaVersion),
+			wantVersion: SchemaVersion,
+		},
+		{
+			name:        "newer version",
+			file:        `{"schema_version": 99, "issues": {}}`,
+			wantVersion: 99,
+			wantErr:     "upgrade mob",
+		},
+		{
+			name:    "fractional version",
+			file:    `{"schema_version": 1.5}`,
+			wantErr: "invalid schema_version",
+		},
+		{
+			name:    "negative version",
+			file:    `{"schema_version": -1}`,
+			wantErr: "invalid schema_version",
+		},
+		{
+			name:    "version that isn't a number",
+			file:    `{"schema_version": "3"}`,
+			wantErr: "invalid schema_version",
+		},
+		{
+			name:    "issue that isn't an object",
+			file:    `{"issues": {"42": "base"}}`,
+			wantErr: "issue 42: expected an object",
+		},
+		{
+			name:    "invalid JSON",
+			file:    `{"issues": `,
+			wantErr: "unexpected end of JSON input",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upgraded, version, err := migrate([]byte(tt.file))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("migrate() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if version != tt.wantVersion {
+				t.Errorf("version = %d, want %d", version, tt.wantVersion)
+			}
+
+			var raw map[string]any
+			if err := json.Unmarshal(upgraded, &raw); err != nil {
+				t.Fatal(err)
+			}
+			if raw["schema_version"] != float64(SchemaVersion) {
+				t.Errorf("schema_version = %v, want %d", raw["schema_version"], SchemaVersion)
+			}
+			if tt.check != nil {
+				tt.check(t, raw)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreLoadMigrates(t *testing.T) {
+	store := &MemoryStore{file: []byte(`{"issues": {"42": {"fork_point": "base", "merged_commits": null}}}`)}
+
+	data, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.SchemaVersion != SchemaVersion {
+		t.Errorf("schema version = %d, want %d", data.SchemaVersion, SchemaVersion)
+	}
+	issue := data.Issues["42"]
+	if issue.ForkPoint != "base" || issue.Mode != ModePerUpdate || issue.MergedCommits == nil {
+		t.Errorf("migrated issue = %+v", issue)
+	}
+
+	// Saving writes the current version, which loads without migrating
+	if err := store.Save(data); err != nil {
+		t.Fatal(err)
+	}
+	if _, version, err := migrate(store.file); err != nil || version != SchemaVersion {
+		t.Errorf("saved data has version %d (error %v), want %d", version, err, SchemaVersion)
+	}
+}
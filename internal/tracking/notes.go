@@ -0,0 +1,195 @@
+package tracking
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/joaosaffran/mob/internal/git"
+)
+
+// NotesStore keeps tracking data in git notes. Each issue is stored in a note on its
+// fork point, so the metadata sits next to the commits it describes. Until the notes
+// ref exists, the fallback is read.
+type NotesStore struct {
+	Ref      string
+	fallback storage
+}
+
+// NewNotesStore returns a Store that keeps tracking data in notes under ref
+func NewNotesStore(ref string) Store {
+	return &NotesStore{Ref: ref}
+}
+
+// documents returns the serialized tracking data of every note, keyed by the commit it is attached to
+func (n *NotesStore) documents() (map[string][]byte, error) {
+	repo := git.Default()
+	if !repo.BranchExists(n.Ref) {
+		if n.fallback == nil {
+			return nil, nil
+		}
+		file, err := n.fallback.read()
+		if err != nil || file == nil {
+			return nil, err
+		}
+		return map[string][]byte{"": file}, nil
+	}
+
+	notes, err := repo.ListNotes(n.Ref)
+	if err != nil {
+		return nil, err
+	}
+	docs := make(map[string][]byte, len(notes))
+	for commit, blob := range notes {
+		if docs[commit], err = repo.ReadBlob(blob); err != nil {
+			return nil, err
+		}
+	}
+	return docs, nil
+}
+
+// Load reads and combines every note. Notes written with an older schema are rewritten;
+// the previous notes stay in the history of the notes ref.
+func (n *NotesStore) Load() (*TrackingData, error) {
+	docs, err := n.documents()
+	if err != nil {
+		return nil, err
+	}
+
+	data, _, _ := parse(nil)
+	oldest := SchemaVersion
+	for commit, doc := range docs {
+		part, version, err := parse(doc)
+		if err != nil {
+			return nil, fmt.Errorf("error reading note on %s: %w", commit, err)
+		}
+		oldest = min(oldest, version)
+		for issue, entry := range part.Issues {
+			data.Issues[issue] = entry
+		}
+		for issue, entry := range part.Archive {
+			if data.Archive == nil {
+				data.Archive = make(map[string]ArchivedIssue)
+			}
+			data.Archive[issue] = entry
+		}
+	}
+
+	if oldest < SchemaVersion {
+		if err := n.Save(data); err != nil {
+			return nil, fmt.Errorf("error saving migrated tracking data: %w", err)
+		}
+	}
+	return data, nil
+}
+
+// Save replaces all notes with one note per fork point holding its issues
+func (n *NotesStore) Save(data *TrackingData) error {
+	repo := git.Default()
+	parts := make(map[string]*TrackingData)
+	part := func(commit string) *TrackingData {
+		if parts[commit] == nil {
+			parts[commit] = &TrackingData{Issues: make(map[string]IssueTracking)}
+		}
+		return parts[commit]
+	}
+
+	for issue, entry := range data.Issues {
+		if entry.ForkPoint == "" {
+			return fmt.Errorf("issue %s has no fork point to attach its note to", issue)
+		}
+		part(entry.ForkPoint).Issues[issue] = entry
+	}
+	for issue, entry := range data.Archive {
+		if entry.ForkPoint == "" {
+			return fmt.Errorf("finished issue %s has no fork point to attach its note to", issue)
+		}
+		p := part(entry.ForkPoint)
+		if p.Archive == nil {
+			p.Archive = make(map[string]ArchivedIssue)
+		}
+		p.Archive[issue] = entry
+	}
+
+	blobs := make(map[string]string, len(parts))
+	for commit, p := range parts {
+		file, err := p.marshal()
+		if err != nil {
+			return err
+		}
+		if blobs[commit], err = repo.HashObject(file); err != nil {
+			return err
+		}
+	}
+
+	tree, err := repo.MakeTree(blobs)
+	if err != nil {
+		return err
+	}
+	return commitToRef(n.Ref, tree)
+}
+
+// SetForkPoint records an issue's fork point, which moves its note to that commit
+func (n *NotesStore) SetForkPoint(issue string, forkPoint string) error {
+	return setForkPoint(n, issue, forkPoint)
+}
+
+// GetUnmergedCommits returns the commits of allCommits the notes don't list as merged
+func (n *NotesStore) GetUnmergedCommits(issue string, allCommits []string, patchIDs PatchIDFunc) ([]string, error) {
+	return getUnmergedCommits(n, issue, allCommits, patchIDs)
+}
+
+func (n *NotesStore) storedVersion() (int, error) {
+	docs, err := n.documents()
+	if err != nil {
+		return 0, err
+	}
+	oldest := SchemaVersion
+	for _, doc := range docs {
+		var raw map[string]any
+		if err := json.Unmarshal(doc, &raw); err != nil {
+			return 0, err
+		}
+		version, err := schemaVersionOf(raw)
+		if err != nil {
+			return 0, err
+		}
+		oldest = min(oldest, version)
+	}
+	return oldest, nil
+}
+
+func (n *NotesStore) validate() ([]string, error) {
+	docs, err := n.documents()
+	if err != nil {
+		return nil, err
+	}
+	commits := make([]string, 0, len(docs))
+	for commit := range docs {
+		commits = append(commits, commit)
+	}
+	sort.Strings(commits)
+
+	var problems []string
+	for _, commit := range commits {
+		for _, problem := range checkDocument(docs[commit]) {
+			if commit != "" {
+				problem = fmt.Sprintf("note on %s: %s", commit[:7], problem)
+			}
+			problems = append(problems, problem)
+		}
+	}
+	return problems, nil
+}
+
+func (n *NotesStore) locked() bool {
+	return true
+}
+
+func (n *NotesStore) sharedRef() string {
+	return n.Ref
+}
+
+func (n *NotesStore) at(ref string) Store {
+	return &NotesStore{Ref: ref}
+}
@@ -0,0 +1,441 @@
+package tracking
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/joaosaffran/mob/internal/config"
+	"github.com/joaosaffran/mob/internal/git"
+)
+
+// refFile is the name of the file holding the tracking data in the tracking ref's tree
+const refFile = "tracking.json"
+
+// Store loads and saves tracking data. Load returns data migrated to SchemaVersion
+// and empty data when nothing was stored yet. SetForkPoint and GetUnmergedCommits
+// work on the stored data directly, like the TrackingData methods of the same name.
+type Store interface {
+	Load() (*TrackingData, error)
+	Save(data *TrackingData) error
+	SetForkPoint(issue string, forkPoint string) error
+	GetUnmergedCommits(issue string, allCommits []string, patchIDs PatchIDFunc) ([]string, error)
+}
+
+// setForkPoint implements Store.SetForkPoint with the store's Load and Save
+func setForkPoint(store Store, issue string, forkPoint string) error {
+	data, err := store.Load()
+	if err != nil {
+		return err
+	}
+	data.SetForkPoint(issue, forkPoint)
+	return store.Save(data)
+}
+
+// getUnmergedCommits implements Store.GetUnmergedCommits with the store's Load
+func getUnmergedCommits(store Store, issue string, allCommits []string, patchIDs PatchIDFunc) ([]string, error) {
+	data, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	return data.GetUnmergedCommits(issue, allCommits, patchIDs), nil
+}
+
+// override replaces the configured store, see SetStore
+var override Store
+
+// SetStore makes Load, Save and Update use store instead of the one selected in
+// .mob/config.yaml. Passing nil goes back to the configured store.
+func SetStore(store Store) {
+	override = store
+}
+
+// getStore returns the store to use
+func getStore() (Store, error) {
+	if override != nil {
+		return override, nil
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("error loading config: %w", err)
+	}
+
+	path, err := getTrackingPath()
+	if err != nil {
+		return nil, err
+	}
+	file := fileStorage{path: path}
+
+	switch cfg.Tracking.Backend {
+	case config.BackendFile:
+		return &serialized{storage: file, repoLock: true}, nil
+	case config.BackendGitRef:
+		return &serialized{storage: refStorage{ref: cfg.Tracking.Ref, fallback: file}, repoLock: true}, nil
+	case config.BackendGitNotes:
+		return &NotesStore{Ref: cfg.Tracking.Ref, fallback: file}, nil
+	default:
+		return nil, fmt.Errorf("unknown tracking backend '%s'", cfg.Tracking.Backend)
+	}
+}
+
+// storage reads and writes a serialized tracking document
+type storage interface {
+	// read returns the stored document, or nil if nothing was stored yet
+	read() ([]byte, error)
+	write(data []byte) error
+}
+
+// serialized is a Store keeping all tracking data in one JSON document. Documents
+// written with an older schema are migrated on Load, keeping a backup of the old one.
+type serialized struct {
+	storage
+	// repoLock is set when the document lives in the repository and needs the tracking lock
+	repoLock bool
+}
+
+// NewFileStore returns a Store that keeps tracking data in a JSON file
+func NewFileStore(path string) Store {
+	return &serialized{storage: fileStorage{path: path}, repoLock: true}
+}
+
+// NewRefStore returns a Store that keeps tracking data in a commit history under a git ref
+func NewRefStore(ref string) Store {
+	return &serialized{storage: refStorage{ref: ref}, repoLock: true}
+}
+
+// Load reads the document, migrating it first if needed
+func (s *serialized) Load() (*TrackingData, error) {
+	file, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+
+	data, version, err := parse(file)
+	if err != nil || version == SchemaVersion {
+		return data, err
+	}
+
+	if _, err := backup(file, version); err != nil {
+		return nil, fmt.Errorf("error backing up tracking data: %w", err)
+	}
+	if err := s.Save(data); err != nil {
+		return nil, fmt.Errorf("error saving migrated tracking data: %w", err)
+	}
+	return data, nil
+}
+
+// Save writes the document
+func (s *serialized) Save(data *TrackingData) error {
+	file, err := data.marshal()
+	if err != nil {
+		return err
+	}
+	return s.write(file)
+}
+
+// SetForkPoint records an issue's fork point in the document
+func (s *serialized) SetForkPoint(issue string, forkPoint string) error {
+	return setForkPoint(s, issue, forkPoint)
+}
+
+// GetUnmergedCommits returns the commits of allCommits the document doesn't list as merged
+func (s *serialized) GetUnmergedCommits(issue string, allCommits []string, patchIDs PatchIDFunc) ([]string, error) {
+	return getUnmergedCommits(s, issue, allCommits, patchIDs)
+}
+
+func (s *serialized) storedVersion() (int, error) {
+	file, err := s.read()
+	if err != nil || file == nil {
+		return SchemaVersion, err
+	}
+	var raw map[string]any
+	if err := json.Unmarshal(file, &raw); err != nil {
+		return 0, err
+	}
+	return schemaVersionOf(raw)
+}
+
+func (s *serialized) validate() ([]string, error) {
+	file, err := s.read()
+	if err != nil || file == nil {
+		return nil, err
+	}
+	return checkDocument(file), nil
+}
+
+func (s *serialized) locked() bool {
+	return s.repoLock
+}
+
+// fileStorage keeps the document in .mob/tracking.json
+type fileStorage struct {
+	path string
+}
+
+func (f fileStorage) read() ([]byte, error) {
+	data, err := os.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// write replaces the file atomically: the data goes to a temporary file in the same
+// directory, which is then renamed over the tracking file, so a crash never leaves it truncated
+func (f fileStorage) write(data []byte) error {
+	// Create directory if it doesn't exist
+	dir := filepath.Dir(f.path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, trackingFile+".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
+
+// refStorage keeps the document in a commit history under a git ref, so it can be
+// pushed and fetched like a branch. Until the ref exists, the fallback is read.
+type refStorage struct {
+	ref      string
+	fallback storage
+}
+
+func (r refStorage) read() ([]byte, error) {
+	repo := git.Default()
+	if !repo.BranchExists(r.ref) {
+		if r.fallback == nil {
+			return nil, nil
+		}
+		return r.fallback.read()
+	}
+	return repo.ReadFileAt(r.ref, refFile)
+}
+
+func (r refStorage) write(data []byte) error {
+	repo := git.Default()
+	blob, err := repo.HashObject(data)
+	if err != nil {
+		return err
+	}
+	tree, err := repo.MakeTree(map[string]string{refFile: blob})
+	if err != nil {
+		return err
+	}
+	return commitToRef(r.ref, tree)
+}
+
+// commitToRef records tree as a new commit on ref, unless ref already has that tree
+func commitToRef(ref, tree string) error {
+	repo := git.Default()
+	old := git.ZeroHash
+	var parents []string
+	if repo.BranchExists(ref) {
+		tip, err := repo.GetCommitHash(ref)
+		if err != nil {
+			return err
+		}
+		// Nothing to record if the data didn't change
+		if current, err := repo.TreeOf(tip); err == nil && current == tree {
+			return nil
+		}
+		old = tip
+		parents = []string{tip}
+	}
+
+	commit, err := repo.CommitTree(tree, git.CommitOptions{
+		Parents: parents,
+		Message: "Update mob tracking data",
+	})
+	if err != nil {
+		return err
+	}
+	return repo.UpdateRef(ref, commit, old)
+}
+
+// sharedStore is a Store kept under a git ref that can be pushed and fetched
+type sharedStore interface {
+	Store
+	sharedRef() string
+	// at returns a store reading the same kind of data from another ref
+	at(ref string) Store
+}
+
+func (s *serialized) sharedRef() string {
+	if r, ok := s.storage.(refStorage); ok {
+		return r.ref
+	}
+	return ""
+}
+
+func (s *serialized) at(ref string) Store {
+	return &serialized{storage: refStorage{ref: ref}}
+}
+
+// getSharedStore returns the configured store and its remote if it can be shared, or nil
+func getSharedStore() (sharedStore, string, error) {
+	store, err := getStore()
+	if err != nil {
+		return nil, "", err
+	}
+	shared, ok := store.(sharedStore)
+	if !ok || shared.sharedRef() == "" {
+		return nil, "", nil
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return nil, "", fmt.Errorf("error loading config: %w", err)
+	}
+	return shared, cfg.Tracking.Remote, nil
+}
+
+// Push publishes the tracking ref to its remote. It does nothing unless tracking is kept in git.
+func Push() error {
+	repo := git.Default()
+	shared, remote, err := getSharedStore()
+	if err != nil || shared == nil {
+		return err
+	}
+	ref := shared.sharedRef()
+	if !repo.BranchExists(ref) {
+		return nil
+	}
+
+	if err := repo.PushRefspec(remote, ref+":"+ref); err != nil {
+		if errors.Is(err, git.ErrNonFastForward) {
+			return fmt.Errorf("%s on %s has newer data; run 'mob tracking pull' first", ref, remote)
+		}
+		return fmt.Errorf("error pushing %s: %w", ref, err)
+	}
+	return nil
+}
+
+// Pull fetches the tracking ref from its remote and merges it into the local one.
+// It does nothing unless tracking is kept in git.
+func Pull() error {
+	repo := git.Default()
+	shared, remote, err := getSharedStore()
+	if err != nil || shared == nil {
+		return err
+	}
+	ref := shared.sharedRef()
+
+	exists, err := repo.RemoteRefExists(remote, ref)
+	if err != nil {
+		return fmt.Errorf("error checking %s on %s: %w", ref, remote, err)
+	}
+	if !exists {
+		return nil
+	}
+
+	// Fetch into a separate ref so local changes are never overwritten
+	name := strings.TrimPrefix(strings.TrimPrefix(ref, "refs/mob/"), "refs/")
+	remoteRef := fmt.Sprintf("refs/mob/remotes/%s/%s", remote, name)
+	if err := repo.FetchRefspec(remote, "+"+ref+":"+remoteRef); err != nil {
+		return fmt.Errorf("error fetching %s: %w", ref, err)
+	}
+	theirs, err := repo.GetCommitHash(remoteRef)
+	if err != nil {
+		return err
+	}
+
+	return withLock(func(Store) error {
+		if repo.BranchExists(ref) {
+			ours, err := repo.GetCommitHash(ref)
+			if err != nil {
+				return err
+			}
+			switch {
+			case repo.IsAncestor(theirs, ours):
+				return nil
+			case repo.IsAncestor(ours, theirs):
+				return repo.UpdateRef(ref, theirs, ours)
+			}
+		}
+
+		// Also keeps anything recorded in the tracking file before the ref existed
+		local, err := shared.Load()
+		if err != nil {
+			return err
+		}
+		if !repo.BranchExists(ref) && len(local.Issues) == 0 && len(local.Archive) == 0 {
+			return repo.UpdateRef(ref, theirs, git.ZeroHash)
+		}
+
+		remoteData, err := shared.at(remoteRef).Load()
+		if err != nil {
+			return fmt.Errorf("error reading remote tracking data: %w", err)
+		}
+		mergeData(local, remoteData)
+		if err := shared.Save(local); err != nil {
+			return err
+		}
+		return joinHistory(ref, theirs)
+	})
+}
+
+// mergeData adds the issues of other to data. For issues tracked on both sides the
+// most recently updated entry wins.
+func mergeData(data, other *TrackingData) {
+	for issue, entry := range other.Issues {
+		if current, ok := data.Issues[issue]; !ok || entry.UpdatedAt.After(current.UpdatedAt) {
+			data.Issues[issue] = entry
+		}
+	}
+	for issue, entry := range other.Archive {
+		if data.Archive == nil {
+			data.Archive = make(map[string]ArchivedIssue)
+		}
+		if _, ok := data.Archive[issue]; !ok {
+			data.Archive[issue] = entry
+		}
+	}
+	// Issues finished on either side stay finished
+	for issue := range data.Archive {
+		delete(data.Issues, issue)
+	}
+}
+
+// joinHistory records theirs as merged into ref, keeping the tree ref points at
+func joinHistory(ref, theirs string) error {
+	repo := git.Default()
+	ours, err := repo.GetCommitHash(ref)
+	if err != nil {
+		return err
+	}
+	tree, err := repo.TreeOf(ours)
+	if err != nil {
+		return err
+	}
+	commit, err := repo.CommitTree(tree, git.CommitOptions{
+		Parents: []string{ours, theirs},
+		Message: "Merge mob tracking data",
+	})
+	if err != nil {
+		return err
+	}
+	return repo.UpdateRef(ref, commit, ours)
+}
@@ -2,16 +2,34 @@ package tracking
 
 import (
 	"encoding/json"
-	"os"
+	"fmt"
 	"path/filepath"
+	"time"
+
+	"github.com/joaosaffran/mob/internal/git"
 )
 
 const trackingDir = ".mob"
 const trackingFile = "tracking.json"
 
+// History modes control how wip commits are carried onto the pr branch
+const (
+	// ModePerUpdate adds one squash commit to the pr branch for every update
+	ModePerUpdate = "per-update"
+	// ModeSquashAll rewrites the pr branch to a single commit on every update
+	ModeSquashAll = "squash-all"
+	// ModePreserve cherry-picks every wip commit onto the pr branch
+	ModePreserve = "preserve"
+)
+
+// Modes lists the supported history modes
+var Modes = []string{ModeSquashAll, ModePerUpdate, ModePreserve}
+
 // TrackingData holds the tracking information for all issues
 type TrackingData struct {
-	Issues map[string]IssueTracking `json:"issues"`
+	SchemaVersion int                      `json:"schema_version"`
+	Issues        map[string]IssueTracking `json:"issues"`
+	Archive       map[string]ArchivedIssue `json:"archive,omitempty"`
 }
 
 // IssueTracking holds the tracking information for a single issue
@@ -19,63 +37,116 @@ type IssueTracking struct {
 	ForkPoint        string   `json:"fork_point"`
 	LastMergedCommit string   `json:"last_merged_commit"`
 	MergedCommits    []string `json:"merged_commits"`
+	// MergedPatchIDs maps merged commits to their patch IDs, so their changes are still
+	// recognized after a rebase or amend gives them new hashes
+	MergedPatchIDs map[string]string `json:"merged_patch_ids,omitempty"`
+	BaseBranch     string            `json:"base_branch,omitempty"`
+	PRNumber       int               `json:"pr_number,omitempty"`
+	PRTip          string            `json:"pr_tip,omitempty"`
+	Mode           string            `json:"mode,omitempty"`
+	UpdatedAt      time.Time         `json:"updated_at,omitzero"`
+	Review         *Review           `json:"review,omitempty"`
+	Events         []Event           `json:"events,omitempty"`
+}
+
+// ArchivedIssue keeps the tracking of a finished issue along with where it was merged
+type ArchivedIssue struct {
+	IssueTracking
+	MergeCommit string    `json:"merge_commit"`
+	MergedAt    time.Time `json:"merged_at,omitzero"`
+	FinishedAt  time.Time `json:"finished_at"`
 }
 
-// getTrackingPath returns the path to the tracking file
+// Review holds the outcome of the last review session for an issue
+type Review struct {
+	Commit string `json:"commit"`
+	// PatchID identifies the reviewed changes, so the review still counts after a rebase
+	PatchID   string       `json:"patch_id,omitempty"`
+	Completed bool         `json:"completed"`
+	Items     []ReviewItem `json:"items"`
+}
+
+// ReviewItem holds the state of a single checklist item
+type ReviewItem struct {
+	Description string `json:"description"`
+	Checked     bool   `json:"checked"`
+}
+
+// getTrackingPath returns the path to the tracking file at the root of the repository,
+// so commands behave the same from any subdirectory
 func getTrackingPath() (string, error) {
-	// Get git root directory
-	cwd, err := os.Getwd()
+	root, err := git.Default().Root()
 	if err != nil {
 		return "", err
 	}
-	return filepath.Join(cwd, trackingDir, trackingFile), nil
+	return filepath.Join(root, trackingDir, trackingFile), nil
 }
 
-// Load loads the tracking data from disk
+// Load loads the tracking data from the configured store. Data written with an
+// older schema is migrated and saved back.
 func Load() (*TrackingData, error) {
-	path, err := getTrackingPath()
-	if err != nil {
-		return nil, err
-	}
+	var data *TrackingData
+	err := withLock(func(store Store) error {
+		var err error
+		data, err = store.Load()
+		return err
+	})
+	return data, err
+}
+
+// Update loads the tracking data, lets fn change it and saves it, holding the lock
+// throughout so changes made by other mob processes in between aren't lost.
+// Nothing is saved if fn returns an error.
+func Update(fn func(*TrackingData) error) error {
+	return withLock(func(store Store) error {
+		data, err := store.Load()
+		if err != nil {
+			return err
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+		return store.Save(data)
+	})
+}
 
+// parse decodes serialized tracking data, migrating it in memory, and returns the
+// schema version it was written with. nil content yields empty data.
+func parse(file []byte) (*TrackingData, int, error) {
 	data := &TrackingData{
-		Issues: make(map[string]IssueTracking),
+		SchemaVersion: SchemaVersion,
+		Issues:        make(map[string]IssueTracking),
 	}
-
-	file, err := os.ReadFile(path)
-	if os.IsNotExist(err) {
-		return data, nil
+	if file == nil {
+		return data, SchemaVersion, nil
 	}
+
+	upgraded, version, err := migrate(file)
 	if err != nil {
-		return nil, err
+		return nil, version, err
 	}
-
-	if err := json.Unmarshal(file, data); err != nil {
-		return nil, err
+	if err := json.Unmarshal(upgraded, data); err != nil {
+		return nil, version, err
+	}
+	if data.Issues == nil {
+		data.Issues = make(map[string]IssueTracking)
 	}
 
-	return data, nil
+	return data, version, nil
 }
 
-// Save saves the tracking data to disk
+// Save saves the tracking data to the configured store, replacing what is stored.
+// Use Update to change data that other processes may be changing too.
 func (t *TrackingData) Save() error {
-	path, err := getTrackingPath()
-	if err != nil {
-		return err
-	}
-
-	// Create directory if it doesn't exist
-	dir := filepath.Dir(path)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return err
-	}
-
-	data, err := json.MarshalIndent(t, "", "  ")
-	if err != nil {
-		return err
-	}
+	return withLock(func(store Store) error {
+		return store.Save(t)
+	})
+}
 
-	return os.WriteFile(path, data, 0644)
+// marshal serializes the tracking data at the current schema version
+func (t *TrackingData) marshal() ([]byte, error) {
+	t.SchemaVersion = SchemaVersion
+	return json.MarshalIndent(t, "", "  ")
 }
 
 // GetIssueTracking returns the tracking data for an issue
@@ -88,12 +159,45 @@ func (t *TrackingData) GetIssueTracking(issue string) IssueTracking {
 	}
 }
 
-// UpdateIssueTracking updates the tracking data for an issue
-func (t *TrackingData) UpdateIssueTracking(issue string, lastCommit string, commits []string) {
+// PatchIDFunc returns the patch IDs of commits, keyed by commit, leaving out commits
+// without changes. (*git.Repo).PatchIDs is one; tests can use a fixed table instead.
+type PatchIDFunc func(commits []string) (map[string]string, error)
+
+// UpdateIssueTracking records commits as merged for an issue, along with their patch IDs
+func (t *TrackingData) UpdateIssueTracking(issue string, lastCommit string, commits []string, patchIDs PatchIDFunc) error {
+	ids, err := patchIDs(commits)
+	if err != nil {
+		return fmt.Errorf("error computing patch IDs: %w", err)
+	}
+
 	tracking := t.GetIssueTracking(issue)
 	tracking.LastMergedCommit = lastCommit
 	tracking.MergedCommits = append(tracking.MergedCommits, commits...)
+	if tracking.MergedPatchIDs == nil {
+		tracking.MergedPatchIDs = make(map[string]string)
+	}
+	for commit, id := range ids {
+		tracking.MergedPatchIDs[commit] = id
+	}
+	tracking.UpdatedAt = time.Now()
+	t.Issues[issue] = tracking
+	return nil
+}
+
+// SetMergedCommits replaces the merged commits for an issue (used after its history is rewritten)
+func (t *TrackingData) SetMergedCommits(issue string, lastCommit string, commits []string, patchIDs PatchIDFunc) error {
+	ids, err := patchIDs(commits)
+	if err != nil {
+		return fmt.Errorf("error computing patch IDs: %w", err)
+	}
+
+	tracking := t.GetIssueTracking(issue)
+	tracking.LastMergedCommit = lastCommit
+	tracking.MergedCommits = append([]string{}, commits...)
+	tracking.MergedPatchIDs = ids
+	tracking.UpdatedAt = time.Now()
 	t.Issues[issue] = tracking
+	return nil
 }
 
 // SetForkPoint sets the fork point for an issue (called when wip branch is created)
@@ -108,19 +212,141 @@ func (t *TrackingData) GetForkPoint(issue string) string {
 	return t.GetIssueTracking(issue).ForkPoint
 }
 
-// GetUnmergedCommits returns commits that haven't been merged yet
-func (t *TrackingData) GetUnmergedCommits(issue string, allCommits []string) []string {
+// GetUnmergedCommits returns commits that haven't been merged yet, newest first like allCommits.
+// A commit whose hash wasn't merged still counts as merged when a merged commit had the same
+// patch ID, as happens after a rebase or amend. Each merged patch covers one commit, so a
+// change that was reverted and made again is still carried over. If patch IDs can't be
+// computed, only hashes are compared.
+func (t *TrackingData) GetUnmergedCommits(issue string, allCommits []string, patchIDs PatchIDFunc) []string {
 	tracking := t.GetIssueTracking(issue)
 	mergedSet := make(map[string]bool)
+	// How many merged commits carried each patch and aren't matched by hash
+	available := make(map[string]int)
 	for _, c := range tracking.MergedCommits {
 		mergedSet[c] = true
+		if id := tracking.MergedPatchIDs[c]; id != "" {
+			available[id]++
+		}
 	}
 
 	var unmerged []string
 	for _, c := range allCommits {
 		if !mergedSet[c] {
 			unmerged = append(unmerged, c)
+		} else if id := tracking.MergedPatchIDs[c]; id != "" {
+			available[id]--
+		}
+	}
+	if len(unmerged) == 0 || len(available) == 0 {
+		return unmerged
+	}
+
+	ids, err := patchIDs(unmerged)
+	if err != nil {
+		return unmerged
+	}
+
+	// Match oldest first, so the older of two commits with the same patch is the merged one
+	var remaining []string
+	for i := len(unmerged) - 1; i >= 0; i-- {
+		c := unmerged[i]
+		if id := ids[c]; id != "" && available[id] > 0 {
+			available[id]--
+			continue
+		}
+		remaining = append([]string{c}, remaining...)
+	}
+	return remaining
+}
+
+// SetBaseBranch sets the branch the issue's pull request targets
+func (t *TrackingData) SetBaseBranch(issue string, baseBranch string) {
+	tracking := t.GetIssueTracking(issue)
+	tracking.BaseBranch = baseBranch
+	t.Issues[issue] = tracking
+}
+
+// GetBaseBranch returns the branch the issue's pull request targets
+func (t *TrackingData) GetBaseBranch(issue string) string {
+	return t.GetIssueTracking(issue).BaseBranch
+}
+
+// SetPRNumber records the pull request opened for an issue
+func (t *TrackingData) SetPRNumber(issue string, number int) {
+	tracking := t.GetIssueTracking(issue)
+	tracking.PRNumber = number
+	t.Issues[issue] = tracking
+}
+
+// SetPRTip records the commit mob last moved the issue's pr branch to
+func (t *TrackingData) SetPRTip(issue string, tip string) {
+	tracking := t.GetIssueTracking(issue)
+	tracking.PRTip = tip
+	t.Issues[issue] = tracking
+}
+
+// SetReview records the outcome of a review session for an issue
+func (t *TrackingData) SetReview(issue string, review Review) {
+	tracking := t.GetIssueTracking(issue)
+	tracking.Review = &review
+	t.Issues[issue] = tracking
+}
+
+// SetMode records the history mode used for an issue
+func (t *TrackingData) SetMode(issue string, mode string) {
+	tracking := t.GetIssueTracking(issue)
+	tracking.Mode = mode
+	t.Issues[issue] = tracking
+}
+
+// GetMode returns the history mode used for an issue, defaulting to ModePerUpdate
+func (t *TrackingData) GetMode(issue string) string {
+	if mode := t.GetIssueTracking(issue).Mode; mode != "" {
+		return mode
+	}
+	return ModePerUpdate
+}
+
+// ArchiveIssue moves an issue out of the active issues into the archive
+func (t *TrackingData) ArchiveIssue(issue string, mergeCommit string, mergedAt time.Time) {
+	if t.Archive == nil {
+		t.Archive = make(map[string]ArchivedIssue)
+	}
+	now := time.Now()
+	t.AddEvent(issue, Event{Type: EventFinish, At: now})
+	t.Archive[issue] = ArchivedIssue{
+		IssueTracking: t.GetIssueTracking(issue),
+		MergeCommit:   mergeCommit,
+		MergedAt:      mergedAt,
+		FinishedAt:    now,
+	}
+	delete(t.Issues, issue)
+}
+
+// RestoreIssue puts an issue's entry, active or archived, back the way it is in snapshot,
+// leaving the other issues alone
+func (t *TrackingData) RestoreIssue(issue string, snapshot *TrackingData) {
+	if tracking, ok := snapshot.Issues[issue]; ok {
+		t.Issues[issue] = tracking
+	} else {
+		delete(t.Issues, issue)
+	}
+	if archived, ok := snapshot.Archive[issue]; ok {
+		if t.Archive == nil {
+			t.Archive = make(map[string]ArchivedIssue)
+		}
+		t.Archive[issue] = archived
+	} else {
+		delete(t.Archive, issue)
+	}
+}
+
+// IsValidMode reports whether mode is a supported history mode
+func IsValidMode(mode string) bool {
+	for _, m := range Modes {
+		if m == mode {
+			return true
 		}
 	}
-	return unmerged
+	return false
 }
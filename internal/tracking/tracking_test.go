@@ -0,0 +1,161 @@
+package tracking
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+// fixedPatchIDs returns a PatchIDFunc that looks commits up in table. Commits missing
+// from it have no changes, like empty commits.
+func fixedPatchIDs(table map[string]string) PatchIDFunc {
+	return func(commits []string) (map[string]string, error) {
+		ids := make(map[string]string)
+		for _, c := range commits {
+			if id, ok := table[c]; ok {
+				ids[c] = id
+			}
+		}
+		return ids, nil
+	}
+}
+
+// failingPatchIDs is a PatchIDFunc for when git can't compute patch IDs
+func failingPatchIDs(commits []string) (map[string]string, error) {
+	return nil, errors.New("patch-id failed")
+}
+
+func TestGetUnmergedCommits(t *testing.T) {
+	tests := []struct {
+		name     string
+		merged   []string
+		mergedID map[string]string
+		all      []string
+		patchIDs PatchIDFunc
+		want     []string
+	}{
+		{
+			name: "nothing merged",
+			all:  []string{"c2", "c1"},
+			want: []string{"c2", "c1"},
+		},
+		{
+			name:   "merged by hash",
+			merged: []string{"c1"},
+			all:    []string{"c3", "c2", "c1"},
+			want:   []string{"c3", "c2"},
+		},
+		{
+			name:     "rebased copies match by patch ID",
+			merged:   []string{"c2", "c1"},
+			mergedID: map[string]string{"c1": "p1", "c2": "p2"},
+			all:      []string{"n3", "n2", "n1"},
+			patchIDs: fixedPatchIDs(map[string]string{"n1": "p1", "n2": "p2", "n3": "p3"}),
+			want:     []string{"n3"},
+		},
+		{
+			name:     "a change made again after a revert is carried",
+			merged:   []string{"c1"},
+			mergedID: map[string]string{"c1": "p1"},
+			all:      []string{"n3", "n2", "n1"},
+			patchIDs: fixedPatchIDs(map[string]string{"n1": "p1", "n2": "revert", "n3": "p1"}),
+			want:     []string{"n3", "n2"},
+		},
+		{
+			name:     "a patch matched by hash isn't matched again",
+			merged:   []string{"c1"},
+			mergedID: map[string]string{"c1": "p1"},
+			all:      []string{"c2", "c1"},
+			patchIDs: fixedPatchIDs(map[string]string{"c2": "p1"}),
+			want:     []string{"c2"},
+		},
+		{
+			name:     "commits without changes never match",
+			merged:   []string{"c1"},
+			mergedID: map[string]string{"c1": "p1"},
+			all:      []string{"n2", "n1"},
+			patchIDs: fixedPatchIDs(map[string]string{"n1": "p1"}),
+			want:     []string{"n2"},
+		},
+		{
+			name:     "only hashes are compared when patch IDs fail",
+			merged:   []string{"c1"},
+			mergedID: map[string]string{"c1": "p1"},
+			all:      []string{"n2", "c1"},
+			patchIDs: failingPatchIDs,
+			want:     []string{"n2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryStore()
+			data := &TrackingData{Issues: map[string]IssueTracking{
+				"42": {ForkPoint: "base", MergedCommits: tt.merged, MergedPatchIDs: tt.mergedID},
+			}}
+			if err := store.Save(data); err != nil {
+				t.Fatal(err)
+			}
+
+			patchIDs := tt.patchIDs
+			if patchIDs == nil {
+				patchIDs = fixedPatchIDs(nil)
+			}
+			got, err := store.GetUnmergedCommits("42", tt.all, patchIDs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("GetUnmergedCommits() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergedCommitsRoundTrip(t *testing.T) {
+	SetStore(NewMemoryStore())
+	defer SetStore(nil)
+
+	patchIDs := fixedPatchIDs(map[string]string{"c1": "p1", "c2": "p2", "n1": "p1", "n2": "p2", "n3": "p3"})
+	err := Update(func(data *TrackingData) error {
+		data.SetForkPoint("42", "base")
+		return data.UpdateIssueTracking("42", "c2", []string{"c2", "c1"}, patchIDs)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := data.GetForkPoint("42"); got != "base" {
+		t.Errorf("fork point = %q, want %q", got, "base")
+	}
+	// A rebase gave the merged commits new hashes
+	got := data.GetUnmergedCommits("42", []string{"n3", "n2", "n1"}, patchIDs)
+	if want := []string{"n3"}; !slices.Equal(got, want) {
+		t.Errorf("GetUnmergedCommits() = %v, want %v", got, want)
+	}
+}
+
+func TestMemoryStoreSetForkPoint(t *testing.T) {
+	store := NewMemoryStore()
+	if err := store.SetForkPoint("42", "base"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := data.GetForkPoint("42"); got != "base" {
+		t.Errorf("fork point = %q, want %q", got, "base")
+	}
+
+	// Load returns a copy, so changing it doesn't change the store
+	data.SetForkPoint("42", "other")
+	if data, _ := store.Load(); data.GetForkPoint("42") != "base" {
+		t.Errorf("changing loaded data changed the store")
+	}
+}
@@ -0,0 +1,222 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/joaosaffran/mob/internal/diff"
+)
+
+// fileTreeNode is a directory or a changed file in the file tree panel
+type fileTreeNode struct {
+	name      string // relative to the parent; directories with a single subdirectory are merged
+	file      int    // index of the file in the diff, or -1 for directories
+	children  []*fileTreeNode
+	collapsed bool
+}
+
+// fileTreeRow is a node visible in the panel, at its nesting depth
+type fileTreeRow struct {
+	node  *fileTreeNode
+	depth int
+}
+
+// fileTree is the tree of changed files shown next to the diff
+type fileTree struct {
+	root   *fileTreeNode
+	rows   []fileTreeRow
+	cursor int
+}
+
+// newFileTree builds the tree of files. Files that appear more than once, such as a file
+// with both staged and unstaged changes, get a leaf each, labeled with their source.
+func newFileTree(files []*diff.File) fileTree {
+	root := &fileTreeNode{file: -1}
+	for i, f := range files {
+		parts := strings.Split(f.Path(), "/")
+		dir := root
+		for _, part := range parts[:len(parts)-1] {
+			dir = dir.child(part)
+		}
+		name := parts[len(parts)-1]
+		if f.Source != "" {
+			name += fmt.Sprintf(" [%s]", f.Source)
+		}
+		dir.children = append(dir.children, &fileTreeNode{name: name, file: i})
+	}
+	root.compact()
+
+	t := fileTree{root: root}
+	t.refresh()
+	return t
+}
+
+// child returns the subdirectory called name, creating it if needed
+func (n *fileTreeNode) child(name string) *fileTreeNode {
+	for _, c := range n.children {
+		if c.file < 0 && c.name == name {
+			return c
+		}
+	}
+	c := &fileTreeNode{name: name, file: -1}
+	n.children = append(n.children, c)
+	return c
+}
+
+// compact merges directories whose only child is a directory and sorts every level,
+// directories first
+func (n *fileTreeNode) compact() {
+	for _, c := range n.children {
+		for c.file < 0 && len(c.children) == 1 && c.children[0].file < 0 {
+			only := c.children[0]
+			c.name += "/" + only.name
+			c.children = only.children
+		}
+		c.compact()
+	}
+	sort.SliceStable(n.children, func(i, j int) bool {
+		a, b := n.children[i], n.children[j]
+		if (a.file < 0) != (b.file < 0) {
+			return a.file < 0
+		}
+		return a.name < b.name
+	})
+}
+
+// refresh lists the rows that aren't hidden in a collapsed directory
+func (t *fileTree) refresh() {
+	t.rows = t.rows[:0]
+	var walk func(n *fileTreeNode, depth int)
+	walk = func(n *fileTreeNode, depth int) {
+		for _, c := range n.children {
+			t.rows = append(t.rows, fileTreeRow{node: c, depth: depth})
+			if c.file < 0 && !c.collapsed {
+				walk(c, depth+1)
+			}
+		}
+	}
+	walk(t.root, 0)
+	t.cursor = max(0, min(t.cursor, len(t.rows)-1))
+}
+
+// move moves the cursor by delta rows
+func (t *fileTree) move(delta int) {
+	t.cursor = max(0, min(t.cursor+delta, len(t.rows)-1))
+}
+
+// selected returns the node under the cursor, or nil for an empty tree
+func (t *fileTree) selected() *fileTreeNode {
+	if len(t.rows) == 0 {
+		return nil
+	}
+	return t.rows[t.cursor].node
+}
+
+// toggle collapses or expands the directory under the cursor
+func (t *fileTree) toggle() {
+	if n := t.selected(); n != nil && n.file < 0 {
+		n.collapsed = !n.collapsed
+		t.refresh()
+	}
+}
+
+// selectFile moves the cursor to a file, expanding the directories it is in
+func (t *fileTree) selectFile(file int) {
+	var expand func(n *fileTreeNode) bool
+	expand = func(n *fileTreeNode) bool {
+		for _, c := range n.children {
+			if c.file == file || (c.file < 0 && expand(c)) {
+				if c.file < 0 {
+					c.collapsed = false
+				}
+				return true
+			}
+		}
+		return false
+	}
+	if !expand(t.root) {
+		return
+	}
+	t.refresh()
+	for i, row := range t.rows {
+		if row.node.file == file {
+			t.cursor = i
+			return
+		}
+	}
+}
+
+// render draws the visible rows within width and height, scrolled to keep the cursor
+// in view. Each file shows whether it was viewed and its line counts.
+func (t *fileTree) render(files []*diff.File, viewed map[string]bool, width, height int, focused bool) string {
+	if len(t.rows) == 0 {
+		return StyleStatus.Render("No files")
+	}
+
+	start := 0
+	if height > 0 && t.cursor >= height {
+		start = t.cursor - height + 1
+	}
+	end := len(t.rows)
+	if height > 0 {
+		end = min(end, start+height)
+	}
+
+	var lines []string
+	for i := start; i < end; i++ {
+		row := t.rows[i]
+		cursor := SymbolNoCursor
+		if focused && i == t.cursor {
+			cursor = SymbolCursor
+		}
+		indent := strings.Repeat("  ", row.depth)
+
+		if row.node.file < 0 {
+			arrow := "▾ "
+			if row.node.collapsed {
+				arrow = "▸ "
+			}
+			lines = append(lines, truncateWidth(cursor+indent+arrow+row.node.name+"/", width))
+			continue
+		}
+
+		f := files[row.node.file]
+		mark, style := "  ", lipgloss.NewStyle()
+		if viewed[f.Label()] {
+			mark, style = SymbolSuccess+" ", StyleStatus
+		}
+		counts := fileCounts(f)
+		name := truncateWidth(cursor+indent+mark+row.node.name, width-lipgloss.Width(counts)-1)
+		padding := max(1, width-lipgloss.Width(name)-lipgloss.Width(counts))
+		lines = append(lines, style.Render(name)+strings.Repeat(" ", padding)+counts)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// fileCounts renders a file's added and removed lines, or its kind for files that have none
+func fileCounts(f *diff.File) string {
+	switch {
+	case f.Submodule != nil:
+		return StyleDiffMeta.Render("sub")
+	case f.LFS != nil:
+		return StyleDiffMeta.Render("lfs")
+	case f.IsBinary:
+		return StyleDiffMeta.Render("bin")
+	}
+	added, removed := f.Counts()
+	return StyleDiffAdded.Render(fmt.Sprintf("+%d", added)) + " " + StyleDiffRemoved.Render(fmt.Sprintf("-%d", removed))
+}
+
+// truncateWidth shortens s to width columns, ending it with "…" when it is cut
+func truncateWidth(s string, width int) string {
+	if width <= 0 || lipgloss.Width(s) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && lipgloss.Width(string(runes))+1 > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "…"
+}
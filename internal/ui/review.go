@@ -7,25 +7,45 @@ import (
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/joaosaffran/mob/internal/diff"
 	"github.com/joaosaffran/mob/internal/llm"
 )
 
 // ChecklistItem represents an item in the review checklist
 type ChecklistItem struct {
 	Description string
+	Checked     bool
+}
+
+// ReviewChanges are the diffs the review UI can show
+type ReviewChanges struct {
+	// Committed holds the changes committed on the wip branch
+	Committed []*diff.File
+	// Worktree holds the committed changes followed by the staged, unstaged and
+	// untracked ones, each labeled with its source
+	Worktree []*diff.File
+	// ShowWorktree starts the review on Worktree
+	ShowWorktree bool
 }
 
 // ReviewModel is the Bubble Tea model for the review UI
 type ReviewModel struct {
-	diff            string
-	rawDiff         string // unhighlighted diff for LLM
-	diffStat        string
+	changes         ReviewChanges
+	showWorktree    bool
+	files           []*diff.File // files of the diff being shown
+	diff            string       // highlighted diff shown in the viewport
+	fileOffsets     []int        // line of the highlighted diff where each file starts
 	checklistItems  []ChecklistItem
 	checked         map[int]bool
 	cursor          int
 	recsCursor      int // cursor for recommendations panel
 	viewport        viewport.Model
-	focusedPanel    string // "checklist", "diff", or "recommendations"
+	focusedPanel    string // "checklist", "files", "diff", or "recommendations"
+	tree            fileTree
+	showTree        bool
+	split           bool            // side-by-side diff instead of unified
+	splitChosen     bool            // split was toggled by hand, so resizing keeps it
+	viewed          map[string]bool // files marked as viewed, by label
 	ready           bool
 	width           int
 	height          int
@@ -35,78 +55,207 @@ type ReviewModel struct {
 	recommendations []llm.Recommendation
 	loadingRecs     bool
 	recsError       string
+	recsGeneration  int    // counts recommendation requests, so stale replies are dropped
 	showModal       bool   // whether to show the recommendation modal
 	modalContent    string // content to display in the modal
 	modalTitle      string // title for the modal
 }
 
 // NewReviewModel creates a new review model
-func NewReviewModel(diff, diffStat, issue string, items []ChecklistItem) ReviewModel {
-	return ReviewModel{
-		diff:           highlightDiff(diff),
-		rawDiff:        diff,
-		diffStat:       diffStat,
+func NewReviewModel(changes ReviewChanges, issue string, items []ChecklistItem) ReviewModel {
+	m := ReviewModel{
+		changes:        changes,
 		checklistItems: items,
 		checked:        make(map[int]bool),
 		cursor:         0,
 		focusedPanel:   "checklist",
 		issue:          issue,
-		loadingRecs:    true,
+		showTree:       true,
+		viewed:         make(map[string]bool),
+	}
+	m.setView(changes.ShowWorktree)
+	return m
+}
+
+// setView switches between the committed changes and the whole working tree. The
+// recommendations are for the diff shown, so they are requested again.
+func (m *ReviewModel) setView(worktree bool) tea.Cmd {
+	m.showWorktree = worktree
+	m.files = m.changes.Committed
+	if worktree {
+		m.files = m.changes.Worktree
+	}
+	m.tree = newFileTree(m.files)
+	m.renderDiff()
+	if m.ready {
+		m.viewport.GotoTop()
+	}
+
+	m.recsGeneration++
+	m.loadingRecs = true
+	m.recommendations = nil
+	m.recsError = ""
+	m.recsCursor = 0
+	return loadRecommendations(m.files, m.recsGeneration)
+}
+
+// renderDiff renders the files in the current mode and width, keeping the file at the
+// top of the viewport in view
+func (m *ReviewModel) renderDiff() {
+	current := m.currentFile()
+	if m.split {
+		// The panel's padding takes two of the viewport's columns
+		m.diff, m.fileOffsets = highlightSplitDiff(m.files, m.viewport.Width-2)
+	} else {
+		m.diff, m.fileOffsets = highlightDiff(m.files)
+	}
+	if len(m.files) == 0 {
+		m.diff = StyleStatus.Render("No changes")
+	}
+	if m.ready {
+		m.viewport.SetContent(m.diff)
+		if current >= 0 && current < len(m.fileOffsets) {
+			m.viewport.SetYOffset(m.fileOffsets[current])
+		}
 	}
 }
 
 // recommendationsMsg is sent when recommendations are loaded
 type recommendationsMsg struct {
+	generation      int
 	recommendations []llm.Recommendation
 	err             error
 }
 
 // loadRecommendations fetches recommendations from LLM
-func loadRecommendations(diff string) tea.Cmd {
+func loadRecommendations(files []*diff.File, generation int) tea.Cmd {
 	return func() tea.Msg {
-		recs, err := llm.GetRecommendations(diff)
-		return recommendationsMsg{recommendations: recs, err: err}
+		recs, err := llm.GetRecommendations(files)
+		return recommendationsMsg{generation: generation, recommendations: recs, err: err}
 	}
 }
 
-// highlightDiff applies syntax highlighting to git diff output
-func highlightDiff(diff string) string {
+// highlightDiff renders the parsed diff with syntax highlighting. It also returns the
+// line each file starts on, for jumping between files.
+func highlightDiff(files []*diff.File) (string, []int) {
 	var result strings.Builder
-	lines := strings.Split(diff, "\n")
-
-	for _, line := range lines {
-		switch {
-		case strings.HasPrefix(line, "+++") || strings.HasPrefix(line, "---"):
-			// File headers
-			result.WriteString(StyleDiffHeader.Render(line))
-		case strings.HasPrefix(line, "@@"):
+	offsets := make([]int, len(files))
+	line := 0
+	write := func(style lipgloss.Style, text string) {
+		result.WriteString(style.Render(text))
+		result.WriteString("\n")
+		line++
+	}
+
+	for i, f := range files {
+		offsets[i] = line
+		if f.Source != "" {
+			write(StyleDiffSource, fmt.Sprintf(" %s ", f.Source))
+		}
+		if f.IsSpecial() {
+			// Show a summary instead of pointer text or binary markers
+			write(StyleDiffHeader, f.Header[0])
+			write(StyleDiffMeta, f.Summary())
+			continue
+		}
+		for _, header := range f.Header {
+			switch {
+			case strings.HasPrefix(header, "--- ") || strings.HasPrefix(header, "+++ "):
+				// File headers
+				write(StyleDiffHeader, header)
+			default:
+				// Diff command, index and file mode info
+				write(StyleDiffMeta, header)
+			}
+		}
+		for _, h := range f.Hunks {
 			// Hunk headers (@@ -1,3 +1,4 @@)
-			result.WriteString(StyleDiffHunk.Render(line))
-		case strings.HasPrefix(line, "+"):
-			// Added lines
-			result.WriteString(StyleDiffAdded.Render(line))
-		case strings.HasPrefix(line, "-"):
-			// Removed lines
-			result.WriteString(StyleDiffRemoved.Render(line))
-		case strings.HasPrefix(line, "diff --git"):
-			// Diff command header
-			result.WriteString(StyleDiffMeta.Render(line))
-		case strings.HasPrefix(line, "index ") || strings.HasPrefix(line, "new file") || strings.HasPrefix(line, "deleted file"):
-			// Index and file mode info
-			result.WriteString(StyleDiffMeta.Render(line))
-		default:
-			// Context lines
-			result.WriteString(StyleDiffContext.Render(line))
+			write(StyleDiffHunk, h.Header())
+			for _, l := range h.Lines {
+				switch l.Kind {
+				case diff.Added:
+					write(StyleDiffAdded, l.Kind.Prefix()+l.Content)
+				case diff.Removed:
+					write(StyleDiffRemoved, l.Kind.Prefix()+l.Content)
+				default:
+					write(StyleDiffContext, l.Kind.Prefix()+l.Content)
+				}
+				if l.NoNewline {
+					write(StyleDiffMeta, "\\ No newline at end of file")
+				}
+			}
+		}
+	}
+
+	return result.String(), offsets
+}
+
+// jumpToFile scrolls the diff to the next (dir > 0) or previous file
+func (m *ReviewModel) jumpToFile(dir int) {
+	current := m.viewport.YOffset
+	if dir > 0 {
+		for _, offset := range m.fileOffsets {
+			if offset > current {
+				m.viewport.SetYOffset(offset)
+				return
+			}
+		}
+		return
+	}
+	for i := len(m.fileOffsets) - 1; i >= 0; i-- {
+		if m.fileOffsets[i] < current {
+			m.viewport.SetYOffset(m.fileOffsets[i])
+			return
+		}
+	}
+}
+
+// currentFile returns the index of the file at the top of the diff viewport, or -1
+func (m ReviewModel) currentFile() int {
+	current := -1
+	for i, offset := range m.fileOffsets {
+		if offset > m.viewport.YOffset {
+			break
+		}
+		current = i
+	}
+	return current
+}
+
+// toggleViewed marks a file as viewed, or clears the mark
+func (m *ReviewModel) toggleViewed(file int) {
+	if file < 0 || file >= len(m.files) {
+		return
+	}
+	label := m.files[file].Label()
+	m.viewed[label] = !m.viewed[label]
+}
+
+// viewedCount returns how many of the files shown are marked as viewed
+func (m ReviewModel) viewedCount() int {
+	count := 0
+	for _, f := range m.files {
+		if m.viewed[f.Label()] {
+			count++
 		}
-		result.WriteString("\n")
 	}
+	return count
+}
 
-	return result.String()
+// layout returns the widths of the file tree and diff panels; the tree is zero when hidden
+func (m ReviewModel) layout() (treeWidth, diffWidth int) {
+	// Account for borders and the gaps between panels
+	diffWidth = m.width - m.sidebarWidth - 5
+	if m.showTree {
+		treeWidth = max(MinFileTreeWidth, min(MaxFileTreeWidth, int(float64(m.width)*FileTreeWidthRatio)))
+		diffWidth -= treeWidth + 2
+	}
+	return treeWidth, diffWidth
 }
 
 // Init implements tea.Model
 func (m ReviewModel) Init() tea.Cmd {
-	return loadRecommendations(m.rawDiff)
+	return loadRecommendations(m.files, m.recsGeneration)
 }
 
 // Update implements tea.Model
@@ -115,6 +264,9 @@ func (m ReviewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case recommendationsMsg:
+		if msg.generation != m.recsGeneration {
+			break
+		}
 		m.loadingRecs = false
 		if msg.err != nil {
 			m.recsError = msg.err.Error()
@@ -135,21 +287,24 @@ func (m ReviewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.sidebarWidth = MaxSidePanelWidth
 		}
 
-		// Calculate diff panel width (account for borders and gap)
-		diffWidth := msg.Width - m.sidebarWidth - 5
+		_, diffWidth := m.layout()
 
 		verticalMargin := HeaderHeight + FooterHeight
 
 		if !m.ready {
 			m.viewport = viewport.New(diffWidth, msg.Height-verticalMargin-2)
-			m.viewport.SetContent(m.diff)
 			m.ready = true
 		} else {
 			m.viewport.Width = diffWidth
 			m.viewport.Height = msg.Height - verticalMargin - 2
-			m.viewport.SetContent(m.diff)
 		}
 
+		// Wide terminals get the side-by-side diff unless it was toggled by hand
+		if !m.splitChosen {
+			m.split = msg.Width >= SplitDiffMinWidth
+		}
+		m.renderDiff()
+
 	case tea.KeyMsg:
 		// Handle modal close first
 		if m.showModal {
@@ -171,9 +326,14 @@ func (m ReviewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 
 		case "tab":
-			// Cycle focus between panels: checklist -> diff -> recommendations -> checklist
+			// Cycle focus between panels: checklist -> files -> diff -> recommendations -> checklist
 			switch m.focusedPanel {
 			case "checklist":
+				m.focusedPanel = "files"
+				if !m.showTree {
+					m.focusedPanel = "diff"
+				}
+			case "files":
 				m.focusedPanel = "diff"
 			case "diff":
 				m.focusedPanel = "recommendations"
@@ -191,6 +351,8 @@ func (m ReviewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				if m.recsCursor > 0 {
 					m.recsCursor--
 				}
+			case "files":
+				m.tree.move(-1)
 			case "diff":
 				m.viewport, cmd = m.viewport.Update(msg)
 				return m, cmd
@@ -206,6 +368,8 @@ func (m ReviewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				if m.recsCursor < len(m.recommendations)-1 {
 					m.recsCursor++
 				}
+			case "files":
+				m.tree.move(1)
 			case "diff":
 				m.viewport, cmd = m.viewport.Update(msg)
 				return m, cmd
@@ -216,6 +380,8 @@ func (m ReviewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			case "checklist":
 				m.checked[m.cursor] = !m.checked[m.cursor]
 				m.allChecked = m.areAllChecked()
+			case "files":
+				m.openTreeSelection()
 			case "recommendations":
 				// Open modal with full recommendation
 				if len(m.recommendations) > 0 && m.recsCursor < len(m.recommendations) {
@@ -227,11 +393,50 @@ func (m ReviewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 
 		case " ":
-			if m.focusedPanel == "checklist" {
+			switch m.focusedPanel {
+			case "checklist":
 				m.checked[m.cursor] = !m.checked[m.cursor]
 				m.allChecked = m.areAllChecked()
+			case "files":
+				m.openTreeSelection()
+			}
+
+		case "v":
+			switch m.focusedPanel {
+			case "files":
+				if n := m.tree.selected(); n != nil {
+					m.toggleViewed(n.file)
+				}
+			case "diff":
+				m.toggleViewed(m.currentFile())
 			}
 
+		case "t":
+			m.showTree = !m.showTree
+			if !m.showTree && m.focusedPanel == "files" {
+				m.focusedPanel = "diff"
+			}
+			_, m.viewport.Width = m.layout()
+			m.renderDiff()
+
+		case "s":
+			m.split = !m.split
+			m.splitChosen = true
+			m.renderDiff()
+
+		case "n", "p":
+			if m.focusedPanel == "diff" {
+				if msg.String() == "n" {
+					m.jumpToFile(1)
+				} else {
+					m.jumpToFile(-1)
+				}
+				m.tree.selectFile(m.currentFile())
+			}
+
+		case "w":
+			return m, m.setView(!m.showWorktree)
+
 		case "pgup", "pgdown", "home", "end":
 			if m.focusedPanel == "diff" {
 				m.viewport, cmd = m.viewport.Update(msg)
@@ -243,6 +448,19 @@ func (m ReviewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
+// openTreeSelection expands or collapses the directory under the tree cursor, or scrolls
+// the diff to the file under it
+func (m *ReviewModel) openTreeSelection() {
+	n := m.tree.selected()
+	switch {
+	case n == nil:
+	case n.file < 0:
+		m.tree.toggle()
+	case n.file < len(m.fileOffsets):
+		m.viewport.SetYOffset(m.fileOffsets[n.file])
+	}
+}
+
 func (m ReviewModel) areAllChecked() bool {
 	for i := range m.checklistItems {
 		if !m.checked[i] {
@@ -264,7 +482,11 @@ func (m ReviewModel) View() string {
 	}
 
 	// Header
-	title := StyleTitle.Render(fmt.Sprintf("Review for issue #%s", m.issue))
+	titleText := fmt.Sprintf("Review for issue #%s", m.issue)
+	if m.showWorktree {
+		titleText += " (with uncommitted changes)"
+	}
+	title := StyleTitle.Render(titleText)
 
 	// Status bar
 	var statusText string
@@ -279,6 +501,8 @@ func (m ReviewModel) View() string {
 		}
 		statusText = StyleStatus.Render(fmt.Sprintf("Checked: %d/%d", checked, len(m.checklistItems)))
 	}
+	progress := fmt.Sprintf("%d/%d files viewed", m.viewedCount(), len(m.files))
+	statusText += StyleStatus.Render(fmt.Sprintf(" %s %s", SymbolBullet, progress))
 
 	// Calculate panel heights (checklist and recommendations share the right side)
 	rightPanelHeight := m.height - HeaderHeight - FooterHeight - 2
@@ -335,7 +559,7 @@ func (m ReviewModel) View() string {
 	// Build diff panel
 	var diffPanel string
 	var diffTitle string
-	diffWidth := m.width - m.sidebarWidth - 5
+	treeWidth, diffWidth := m.layout()
 	if m.focusedPanel == "diff" {
 		diffTitle = StylePanelTitle.Render("Diff")
 		diffPanel = StylePanelActive.
@@ -350,16 +574,29 @@ func (m ReviewModel) View() string {
 			Render(m.viewport.View())
 	}
 
-	// Combine panels side by side (diff on left, checklist+recs on right)
+	// Combine panels side by side (file tree and diff on left, checklist+recs on right)
 	titles := lipgloss.JoinHorizontal(lipgloss.Top,
 		lipgloss.NewStyle().Width(diffWidth).Render(diffTitle),
 		"  ",
 		rightSideTitles,
 	)
 	panels := lipgloss.JoinHorizontal(lipgloss.Top, diffPanel, "  ", rightSidePanels)
+	if m.showTree {
+		treeTitle := StylePanelTitleInactive.Render("Files")
+		treeStyle := StylePanelInactive
+		if m.focusedPanel == "files" {
+			treeTitle = StylePanelTitle.Render("Files")
+			treeStyle = StylePanelActive
+		}
+		// The panel's padding takes two of its columns
+		treeContent := m.tree.render(m.files, m.viewed, treeWidth-4, rightPanelHeight, m.focusedPanel == "files")
+		treePanel := treeStyle.Width(treeWidth - 2).Height(rightPanelHeight).Render(treeContent)
+		titles = lipgloss.JoinHorizontal(lipgloss.Top, lipgloss.NewStyle().Width(treeWidth).Render(treeTitle), "  ", titles)
+		panels = lipgloss.JoinHorizontal(lipgloss.Top, treePanel, "  ", panels)
+	}
 
 	// Footer
-	footer := StyleStatus.Render(fmt.Sprintf("Tab: switch panel %s ↑/↓: navigate %s Space/Enter: toggle/view %s q: quit", SymbolBullet, SymbolBullet, SymbolBullet))
+	footer := StyleStatus.Render(fmt.Sprintf("Tab: switch panel %s ↑/↓: navigate %s n/p: next/previous file %s v: mark viewed %s t: file tree %s s: split %s w: uncommitted changes %s Space/Enter: toggle/view %s q: quit", SymbolBullet, SymbolBullet, SymbolBullet, SymbolBullet, SymbolBullet, SymbolBullet, SymbolBullet, SymbolBullet))
 
 	return fmt.Sprintf("%s\n%s\n%s\n%s\n\n%s", title, statusText, titles, panels, footer)
 }
@@ -488,7 +725,7 @@ func (m ReviewModel) renderChecklistContent() string {
 	sb.WriteString("\n")
 	sb.WriteString(StyleStatus.Render("─── Stats ───"))
 	sb.WriteString("\n")
-	sb.WriteString(m.diffStat)
+	sb.WriteString(diff.Stat(m.files, m.sidebarWidth-4))
 
 	return sb.String()
 }
@@ -498,19 +735,34 @@ func (m ReviewModel) IsReviewComplete() bool {
 	return m.allChecked
 }
 
-// RunReview starts the review UI and returns whether the review was completed
-func RunReview(diff, diffStat, issue string, items []ChecklistItem) (bool, error) {
-	model := NewReviewModel(diff, diffStat, issue, items)
+// ReviewResult holds the outcome of a review session
+type ReviewResult struct {
+	Completed bool
+	Items     []ChecklistItem
+}
+
+// Result returns the checklist state of the review
+func (m ReviewModel) Result() ReviewResult {
+	items := make([]ChecklistItem, len(m.checklistItems))
+	for i, item := range m.checklistItems {
+		items[i] = ChecklistItem{Description: item.Description, Checked: m.checked[i]}
+	}
+	return ReviewResult{Completed: m.IsReviewComplete(), Items: items}
+}
+
+// RunReview starts the review UI and returns the checklist state when it exits
+func RunReview(changes ReviewChanges, issue string, items []ChecklistItem) (ReviewResult, error) {
+	model := NewReviewModel(changes, issue, items)
 	p := tea.NewProgram(model, tea.WithAltScreen())
 
 	finalModel, err := p.Run()
 	if err != nil {
-		return false, fmt.Errorf("error running review UI: %w", err)
+		return ReviewResult{}, fmt.Errorf("error running review UI: %w", err)
 	}
 
 	if m, ok := finalModel.(ReviewModel); ok {
-		return m.IsReviewComplete(), nil
+		return m.Result(), nil
 	}
 
-	return false, nil
+	return ReviewResult{}, nil
 }
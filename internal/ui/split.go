@@ -0,0 +1,130 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/joaosaffran/mob/internal/diff"
+)
+
+// splitSeparator divides the old and new columns of the side-by-side diff
+const splitSeparator = " │ "
+
+// splitSide is one half of a side-by-side row; a zero line number leaves the side empty
+type splitSide struct {
+	number  int
+	content string
+	style   lipgloss.Style
+}
+
+// highlightSplitDiff renders the parsed diff as old and new columns within width, pairing
+// removed lines with the added lines that replace them. Like highlightDiff, it also
+// returns the line each file starts on.
+func highlightSplitDiff(files []*diff.File, width int) (string, []int) {
+	var result strings.Builder
+	offsets := make([]int, len(files))
+	line := 0
+	write := func(text string) {
+		result.WriteString(text)
+		result.WriteString("\n")
+		line++
+	}
+
+	// Each column gets half of what the separator leaves
+	columnWidth := max(1, (width-lipgloss.Width(splitSeparator))/2)
+
+	for i, f := range files {
+		offsets[i] = line
+		if f.Source != "" {
+			write(StyleDiffSource.Render(fmt.Sprintf(" %s ", f.Source)))
+		}
+		if f.IsSpecial() {
+			write(StyleDiffHeader.Render(truncateWidth(f.Header[0], width)))
+			write(StyleDiffMeta.Render(truncateWidth(f.Summary(), width)))
+			continue
+		}
+		// The file names are in the column headers instead of ---/+++ lines
+		write(StyleDiffHeader.Render(truncateWidth(f.Header[0], width)))
+		oldName, newName := f.OldPath, f.NewPath
+		if oldName == "" {
+			oldName = diff.DevNull
+		}
+		if newName == "" {
+			newName = diff.DevNull
+		}
+		write(StyleDiffMeta.Render(padWidth(truncateWidth(oldName, columnWidth), columnWidth)) +
+			StyleStatus.Render(splitSeparator) +
+			StyleDiffMeta.Render(truncateWidth(newName, columnWidth)))
+
+		for _, h := range f.Hunks {
+			write(StyleDiffHunk.Render(truncateWidth(h.Header(), width)))
+			numberWidth := len(fmt.Sprint(max(h.OldStart+h.OldLines, h.NewStart+h.NewLines)))
+			for _, row := range splitRows(h) {
+				write(renderSplitSide(row[0], numberWidth, columnWidth) +
+					StyleStatus.Render(splitSeparator) +
+					strings.TrimRight(renderSplitSide(row[1], numberWidth, columnWidth), " "))
+			}
+		}
+	}
+
+	return result.String(), offsets
+}
+
+// splitRows pairs the lines of a hunk into old and new sides. Context lines fill both
+// sides; a run of removed lines is matched row by row with the added lines after it, and
+// the shorter run is padded with empty sides so later context stays aligned.
+func splitRows(h diff.Hunk) [][2]splitSide {
+	var rows [][2]splitSide
+	var removed, added []splitSide
+	flush := func() {
+		for i := 0; i < max(len(removed), len(added)); i++ {
+			var row [2]splitSide
+			if i < len(removed) {
+				row[0] = removed[i]
+			}
+			if i < len(added) {
+				row[1] = added[i]
+			}
+			rows = append(rows, row)
+		}
+		removed, added = nil, nil
+	}
+
+	for _, l := range h.Lines {
+		switch l.Kind {
+		case diff.Removed:
+			// A removal after additions starts a new change block
+			if len(added) > 0 {
+				flush()
+			}
+			removed = append(removed, splitSide{number: l.OldLine, content: l.Content, style: StyleDiffRemoved})
+		case diff.Added:
+			added = append(added, splitSide{number: l.NewLine, content: l.Content, style: StyleDiffAdded})
+		default:
+			flush()
+			rows = append(rows, [2]splitSide{
+				{number: l.OldLine, content: l.Content, style: StyleDiffContext},
+				{number: l.NewLine, content: l.Content, style: StyleDiffContext},
+			})
+		}
+	}
+	flush()
+	return rows
+}
+
+// renderSplitSide renders a line number and content padded to width columns
+func renderSplitSide(side splitSide, numberWidth, width int) string {
+	if side.number == 0 {
+		return strings.Repeat(" ", width)
+	}
+	number := fmt.Sprintf("%*d ", numberWidth, side.number)
+	content := strings.ReplaceAll(side.content, "\t", "    ")
+	content = padWidth(truncateWidth(content, width-len(number)), width-len(number))
+	return StyleStatus.Render(number) + side.style.Render(content)
+}
+
+// padWidth fills s with spaces up to width columns
+func padWidth(s string, width int) string {
+	return s + strings.Repeat(" ", max(0, width-lipgloss.Width(s)))
+}
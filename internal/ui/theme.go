@@ -49,6 +49,14 @@ const (
 	SidePanelWidthRatio = 0.3
 	MinSidePanelWidth   = 30
 	MaxSidePanelWidth   = 50
+
+	// File tree width ratio, when the tree is shown
+	FileTreeWidthRatio = 0.2
+	MinFileTreeWidth   = 24
+	MaxFileTreeWidth   = 40
+
+	// Terminals at least this wide start with the side-by-side diff
+	SplitDiffMinWidth = 180
 )
 
 // Symbols used throughout the UI
@@ -126,6 +134,12 @@ var (
 	StyleDiffContext = lipgloss.NewStyle().
 				Foreground(ColorDiffContext)
 
+	// Source label above files that aren't committed yet
+	StyleDiffSource = lipgloss.NewStyle().
+			Bold(true).
+			Foreground(ColorTextBright).
+			Background(ColorBgHighlight)
+
 	// Success/Error message styles
 	StyleSuccess = lipgloss.NewStyle().
 			Foreground(ColorSuccess)